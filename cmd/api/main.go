@@ -1,16 +1,38 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/auth"
 	"github/yusupovkuzs/GoNotesApp/internal/config"
 	"github/yusupovkuzs/GoNotesApp/internal/handlers"
+	"github/yusupovkuzs/GoNotesApp/internal/health"
+	"github/yusupovkuzs/GoNotesApp/internal/lifecycle"
+	"github/yusupovkuzs/GoNotesApp/internal/metrics"
 	mwLogger "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/notify"
+	"github/yusupovkuzs/GoNotesApp/internal/revocation"
 	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/memory"
 	"github/yusupovkuzs/GoNotesApp/internal/storage/postgres"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/sqlite"
+	"github/yusupovkuzs/GoNotesApp/internal/tracing"
+	"github/yusupovkuzs/GoNotesApp/internal/trash"
 	"github/yusupovkuzs/GoNotesApp/pkg/logger"
 	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/mailer"
+	"github/yusupovkuzs/GoNotesApp/pkg/password"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -19,49 +41,171 @@ import (
 func main() {
 	// config
 	cfg := config.MustLoad()
-	// logger
-	log := logger.SetupLogger(cfg.Env)
+	// logger, records logged with a request's context carry its trace
+	log := slog.New(tracing.NewLogHandler(logger.SetupLogger(cfg.Env).Handler()))
 	log.Info("Starting Notes App", slog.String("env", cfg.Env))
 	log.Debug("Debug messages are enabled")
 
-	// storage
-	database, err := storage.NewStoragePostgres(cfg.Postgres)
-	if err != nil {
-		log.Error("DB connection failed: %w", sl.Err(err))
-		os.Exit(1)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, log, os.Args[2:]); err != nil {
+			log.Error("migrate failed", sl.Err(err))
+			os.Exit(1)
+		}
+		return
 	}
-	log.Info("Database connected successfully")
 
-	// migrations
-	if err = storage.RunMigrations(database.DB); err != nil {
-		log.Error("Migrations failed: %w", sl.Err(err))
+	// tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("invalid tracing config", sl.Err(err))
 		os.Exit(1)
 	}
-	log.Info("Migrations completed")
+
+	// metrics
+	appMetrics := metrics.New()
 
 	// router
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
+	router.Use(tracing.Middleware)
 	router.Use(mwLogger.New(log))
+	if cfg.Metrics.Enabled {
+		router.Use(appMetrics.Middleware)
+	}
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		response.RespondProblem(w, r, http.StatusNotFound, response.CodeNotFound, "route not found")
+	})
+	router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		response.RespondProblem(w, r, http.StatusMethodNotAllowed, response.CodeMethodNotAllowed, "method not allowed")
+	})
+
+	// password hashing
+	hasher, err := password.New(password.Config{
+		Algorithm:         cfg.Password.Algorithm,
+		BcryptCost:        cfg.Password.BcryptCost,
+		Argon2Memory:      cfg.Password.Argon2Memory,
+		Argon2Iterations:  cfg.Password.Argon2Iterations,
+		Argon2Parallelism: cfg.Password.Argon2Parallelism,
+	})
+	if err != nil {
+		log.Error("invalid password hashing config", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// token signing keys
+	keys, err := auth.NewKeySet(cfg.JWT)
+	if err != nil {
+		log.Error("invalid jwt config", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// health checks
+	checks := health.NewRegistry(cfg.Health)
+
+	// storage
+	repos, db, err := setupStorage(cfg, hasher, checks, log)
+	if err != nil {
+		log.Error("storage setup failed", sl.Err(err))
+		os.Exit(1)
+	}
+	if db != nil {
+		appMetrics.RegisterDB(db, cfg.Storage.Driver)
+		appMetrics.RegisterTotals(db, cfg.Storage.QueryTimeout)
+	}
 
-	noteRepo := postgres.NewNoteRepoPostgres(database.DB)
-	userRepo := postgres.NewUserRepoPostgres(database.DB)
-	handler := handlers.NewHandlers(noteRepo, userRepo)
+	mail, err := setupMailer(cfg.Mail)
+	if err != nil {
+		log.Error("invalid mail config", sl.Err(err))
+		os.Exit(1)
+	}
+	if cfg.Mail.Driver == "smtp" {
+		checks.Register("smtp", health.DialCheck(net.JoinHostPort(cfg.Mail.SMTPHost, strconv.Itoa(cfg.Mail.SMTPPort))))
+	}
+
+	notifier, err := notify.New(cfg.Notifier, log, mail)
+	if err != nil {
+		log.Error("invalid notifier config", sl.Err(err))
+		os.Exit(1)
+	}
+
+	revocations := revocation.NewChecker(repos.Revocations, cfg.Auth.RevocationCacheTTL)
+	tokens := auth.NewTokenService(keys, cfg.JWT)
+	handler := handlers.NewHandlers(handlers.Deps{
+		NoteRepo:     repos.Notes,
+		TagRepo:      repos.Tags,
+		NotebookRepo: repos.Notebooks,
+		RevisionRepo: repos.Revisions,
+		UserRepo:     repos.Users,
+		RefreshRepo:  repos.RefreshTokens,
+		MFARepo:      repos.MFA,
+		ResetRepo:    repos.PasswordResets,
+		Notifier:     notifier,
+		Tokens:       tokens,
+		Verifier:     tokens,
+		Revocations:  revocations,
+		Keys:         keys,
+		Throttle:     auth.NewLoginThrottle(cfg.Auth),
+		AuditLog:     log.With(slog.String("log_type", "audit")),
+		Metrics:      appMetrics,
+
+		PublicURL:            cfg.HttpServer.PublicURL,
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+	})
+
+	readiness := &lifecycle.Readiness{}
+	workers := lifecycle.NewWorkers(log)
+	workers.Go("trash purger", trash.NewPurger(repos.Notes, cfg.Trash, log).Run)
+
+	router.Get("/healthz", health.Liveness())
+	router.Get("/readyz", checks.Readiness(readiness))
+	router.Get("/.well-known/jwks.json", handler.JWKS(log))
 
 	router.Route("/auth", func(r chi.Router) {
 		r.Post("/register", handler.Register(log))
 		r.Post("/login", handler.Login(log))
+		r.Post("/login/mfa", handler.LoginMFA(log))
+		r.Post("/password/forgot", handler.ForgotPassword(log))
+		r.Post("/password/reset", handler.ResetPassword(log))
+		r.Get("/email/verify", handler.VerifyEmail(log))
+		r.Post("/refresh", handler.Refresh(log))
+		r.With(handler.UserIdentity(log)).Post("/logout", handler.Logout(log))
+		r.With(handler.UserIdentity(log)).Post("/logout-all", handler.LogoutAll(log))
 	})
 
 	router.Route("/users", func(r chi.Router) {
 		r.Use(handler.UserIdentity(log))
+		r.Post("/2fa/enroll", handler.EnrollTOTP(log))
+		r.Post("/2fa/confirm", handler.ConfirmTOTP(log))
+		r.Post("/2fa/disable", handler.DisableTOTP(log))
+		r.Put("/password", handler.ChangePassword(log))
+		r.Post("/email/verification", handler.ResendEmailVerification(log))
 		r.Post("/notes", handler.CreateNote(log))
 		r.Get("/notes", handler.GetAllNotes(log))
+		r.Get("/notes/search", handler.SearchNotes(log))
 		r.Get("/notes/{note_id}", handler.GetNote(log))
 		r.Put("/notes/{note_id}", handler.UpdateNote(log))
 		r.Delete("/notes/{note_id}", handler.DeleteNote(log))
+		r.Post("/notes/{note_id}/move", handler.MoveNote(log))
+		r.Get("/notes/{note_id}/revisions", handler.GetRevisions(log))
+		r.Get("/notes/{note_id}/revisions/diff", handler.DiffRevisions(log))
+		r.Get("/notes/{note_id}/revisions/{revision_id}", handler.GetRevision(log))
+		r.Post("/notes/{note_id}/revisions/{revision_id}/restore", handler.RestoreRevision(log))
+		r.Get("/trash", handler.GetTrash(log))
+		r.Post("/trash/{note_id}/restore", handler.RestoreNote(log))
+		r.Delete("/trash/{note_id}", handler.PurgeNote(log))
+		r.Post("/notebooks", handler.CreateNotebook(log))
+		r.Get("/notebooks", handler.GetNotebooks(log))
+		r.Get("/notebooks/{notebook_id}", handler.GetNotebook(log))
+		r.Put("/notebooks/{notebook_id}", handler.RenameNotebook(log))
+		r.Delete("/notebooks/{notebook_id}", handler.DeleteNotebook(log))
+		r.Post("/notebooks/{notebook_id}/move", handler.MoveNotebook(log))
+		r.Get("/notebooks/{notebook_id}/notes", handler.GetNotebookNotes(log))
+		r.Get("/tags", handler.ListTags(log))
+		r.Put("/tags/{tag}", handler.RenameTag(log))
+		r.Post("/tags/{tag}/merge", handler.MergeTag(log))
+		r.Delete("/tags/{tag}", handler.DeleteTag(log))
 	})
 
 	// start server
@@ -72,11 +216,203 @@ func main() {
 		ReadTimeout:  cfg.HttpServer.ReadTimeout,
 		WriteTimeout: cfg.HttpServer.WriteTimeout,
 	}
+	servers := []*http.Server{srv}
 
-	if err = srv.ListenAndServe(); err != nil {
-		log.Error("failed to stop server")
-		return
+	if cfg.Metrics.Enabled && cfg.Metrics.AdminAddress != "" {
+		// the admin listener is shut down last, metrics stay scrapable while draining
+		admin := http.NewServeMux()
+		admin.Handle(cfg.Metrics.Path, appMetrics.Handler())
+		log.Info("starting admin server", slog.String("address", cfg.Metrics.AdminAddress))
+		servers = append(servers, &http.Server{
+			Addr:        cfg.Metrics.AdminAddress,
+			Handler:     admin,
+			ReadTimeout: cfg.HttpServer.ReadTimeout,
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// bind every address up front, readiness must not be reported before
+	// connections can be accepted
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, s := range servers {
+		ln, err := net.Listen("tcp", s.Addr)
+		if err != nil {
+			log.Error("failed to listen", slog.String("address", s.Addr), sl.Err(err))
+			os.Exit(1)
+		}
+		listeners = append(listeners, ln)
 	}
 
+	serverErr := make(chan error, len(servers))
+	for i, s := range servers {
+		go func() {
+			serverErr <- s.Serve(listeners[i])
+		}()
+	}
+	readiness.SetReady(true)
+
+	var failed bool
+	select {
+	case <-ctx.Done():
+		log.Info("shutdown signal received")
+	case err = <-serverErr:
+		log.Error("server failed", sl.Err(err))
+		failed = true
+	}
+	// a second signal kills the process right away
+	stop()
+
+	shutdown(cfg.HttpServer, log, servers, readiness, workers, db)
+	// spans recorded while draining are flushed too
+	if err = shutdownTracing(context.Background()); err != nil {
+		log.Error("failed to flush traces", sl.Err(err))
+	}
+	if failed {
+		os.Exit(1)
+	}
 	log.Info("server stopped")
 }
+
+// shutdown stops taking new traffic, lets in-flight requests finish and then
+// releases what they depend on: workers first and the database last.
+func shutdown(cfg config.HttpServerConfig, log *slog.Logger, servers []*http.Server, readiness *lifecycle.Readiness, workers *lifecycle.Workers, db *sql.DB) {
+	readiness.SetReady(false)
+	if cfg.ShutdownDelay > 0 {
+		log.Info("waiting for load balancers to notice", slog.Duration("delay", cfg.ShutdownDelay))
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error("failed to drain in-flight requests", slog.String("address", srv.Addr), sl.Err(err))
+		}
+	}
+	if err := workers.Stop(ctx); err != nil {
+		log.Error("failed to stop background workers", sl.Err(err))
+	}
+
+	if db == nil {
+		return
+	}
+	if err := db.Close(); err != nil {
+		log.Error("failed to close database", sl.Err(err))
+	}
+}
+
+func setupStorage(cfg *config.Config, hasher *password.Hasher, checks *health.Registry, log *slog.Logger) (storage.Repositories, *sql.DB, error) {
+	timeout := cfg.Storage.QueryTimeout
+
+	if cfg.Storage.Driver == "memory" {
+		log.Warn("using in-memory storage, data is lost on restart")
+
+		db := memory.NewDB()
+		return storage.Repositories{
+			Notes:          memory.NewNoteRepoMemory(db, cfg.Revisions.MaxPerNote),
+			Tags:           memory.NewTagRepoMemory(db),
+			Notebooks:      memory.NewNotebookRepoMemory(db),
+			Revisions:      memory.NewRevisionRepoMemory(db),
+			Users:          memory.NewUserRepoMemory(db, hasher),
+			RefreshTokens:  memory.NewRefreshTokenRepoMemory(db, cfg.Auth.RefreshTokenTTL),
+			MFA:            memory.NewMFARepoMemory(db),
+			PasswordResets: memory.NewPasswordResetRepoMemory(db, cfg.Password.ResetTokenTTL),
+			Revocations:    memory.NewRevocationRepoMemory(db),
+		}, nil, nil
+	}
+
+	db, err := openDatabase(cfg, log)
+	if err != nil {
+		return storage.Repositories{}, nil, err
+	}
+
+	migrator, err := storage.NewMigrator(db, cfg.Storage.Driver)
+	if err != nil {
+		return storage.Repositories{}, nil, fmt.Errorf("migrations setup failed: %w", err)
+	}
+	if cfg.Storage.AutoMigrate {
+		results, err := migrator.Up(context.Background())
+		if err != nil {
+			return storage.Repositories{}, nil, fmt.Errorf("migrations failed: %w", err)
+		}
+		log.Info("Migrations completed", slog.Int("applied", len(results)))
+	} else if err = migrator.Check(context.Background()); err != nil {
+		// readiness stays failing until the migrations are run
+		log.Warn("schema is not up to date", sl.Err(err))
+	}
+
+	checks.Register("database", health.PingCheck(db))
+	checks.Register("migrations", migrator.Check)
+
+	switch cfg.Storage.Driver {
+	case "postgres":
+		// an unknown language would only surface on the first note written
+		if _, err = db.ExecContext(context.Background(), "SELECT $1::regconfig", cfg.Search.Language); err != nil {
+			return storage.Repositories{}, nil, fmt.Errorf("invalid search language %q: %w", cfg.Search.Language, err)
+		}
+		return storage.Repositories{
+			Notes:          postgres.NewNoteRepoPostgres(db, cfg.Search.Language, cfg.Revisions.MaxPerNote, timeout),
+			Tags:           postgres.NewTagRepoPostgres(db, timeout),
+			Notebooks:      postgres.NewNotebookRepoPostgres(db, timeout),
+			Revisions:      postgres.NewRevisionRepoPostgres(db, timeout),
+			Users:          postgres.NewUserRepoPostgres(db, hasher, timeout),
+			RefreshTokens:  postgres.NewRefreshTokenRepoPostgres(db, cfg.Auth.RefreshTokenTTL, timeout),
+			MFA:            postgres.NewMFARepoPostgres(db, timeout),
+			PasswordResets: postgres.NewPasswordResetRepoPostgres(db, cfg.Password.ResetTokenTTL, timeout),
+			Revocations:    postgres.NewRevocationRepoPostgres(db, timeout),
+		}, db, nil
+	default:
+		return storage.Repositories{
+			Notes:          sqlite.NewNoteRepoSQLite(db, cfg.Revisions.MaxPerNote, timeout),
+			Tags:           sqlite.NewTagRepoSQLite(db, timeout),
+			Notebooks:      sqlite.NewNotebookRepoSQLite(db, timeout),
+			Revisions:      sqlite.NewRevisionRepoSQLite(db, timeout),
+			Users:          sqlite.NewUserRepoSQLite(db, hasher, timeout),
+			RefreshTokens:  sqlite.NewRefreshTokenRepoSQLite(db, cfg.Auth.RefreshTokenTTL, timeout),
+			MFA:            sqlite.NewMFARepoSQLite(db, timeout),
+			PasswordResets: sqlite.NewPasswordResetRepoSQLite(db, cfg.Password.ResetTokenTTL, timeout),
+			Revocations:    sqlite.NewRevocationRepoSQLite(db, timeout),
+		}, db, nil
+	}
+}
+
+// openDatabase connects to the SQL database behind the configured storage driver.
+func openDatabase(cfg *config.Config, log *slog.Logger) (*sql.DB, error) {
+	switch cfg.Storage.Driver {
+	case "postgres":
+		database, err := storage.NewStoragePostgres(cfg.Postgres, log)
+		if err != nil {
+			return nil, fmt.Errorf("db connection failed: %w", err)
+		}
+		log.Info("Database connected successfully")
+
+		return database.DB, nil
+	case "sqlite":
+		database, err := storage.NewStorageSQLite(cfg.SQLite)
+		if err != nil {
+			return nil, fmt.Errorf("db connection failed: %w", err)
+		}
+		log.Info("Database connected successfully", slog.String("path", cfg.SQLite.Path))
+
+		return database.DB, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+func setupMailer(cfg config.MailConfig) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("smtp_host is required for the smtp mail driver")
+		}
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.DropDir, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+	}
+}
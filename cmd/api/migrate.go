@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `usage: api migrate [-dir path] <command>
+
+commands:
+  up            apply all pending migrations
+  down          roll back the latest migration
+  to <version>  migrate up or down to the given version
+  status        list migrations and whether they are applied
+  create <name> add an empty SQL migration to -dir`
+
+// runMigrate runs the migrate subcommand, letting deploys migrate the schema
+// as a separate step when auto_migrate is off.
+func runMigrate(cfg *config.Config, log *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), migrateUsage) }
+	dir := flags.String("dir", defaultMigrationsDir(cfg.Storage.Driver), "source directory for create")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing command")
+	}
+	command, rest := flags.Arg(0), flags.Args()[1:]
+
+	// create only writes a file, it doesn't need the database
+	if command == "create" {
+		if len(rest) != 1 {
+			return errors.New("create needs a migration name")
+		}
+		path, err := createMigration(*dir, rest[0])
+		if err != nil {
+			return err
+		}
+		log.Info("migration created", slog.String("file", path))
+		return nil
+	}
+
+	db, err := openDatabase(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := storage.NewMigrator(db, cfg.Storage.Driver)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		results, err := migrator.Up(ctx)
+		logResults(log, results...)
+		return err
+	case "down":
+		result, err := migrator.Down(ctx)
+		if storage.IsNoChange(err) {
+			log.Info("no migration to roll back")
+			return nil
+		}
+		if result != nil {
+			logResults(log, result)
+		}
+		return err
+	case "to":
+		if len(rest) != 1 {
+			return errors.New("to needs a version")
+		}
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", rest[0])
+		}
+		results, err := migrator.To(ctx, version)
+		logResults(log, results...)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func defaultMigrationsDir(driver string) string {
+	if driver == "sqlite" {
+		return filepath.Join("migrations", "sqlite")
+	}
+	return "migrations"
+}
+
+const migrationTemplate = `-- +goose Up
+
+-- +goose Down
+`
+
+// createMigration adds an empty migration numbered after the highest one in dir.
+func createMigration(dir, name string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var last int
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok || filepath.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		if v, err := strconv.Atoi(prefix); err == nil && v > last {
+			last = v
+		}
+	}
+
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	path := filepath.Join(dir, fmt.Sprintf("%04d_%s.sql", last+1, name))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err = f.WriteString(migrationTemplate); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func logResults(log *slog.Logger, results ...*goose.MigrationResult) {
+	if len(results) == 0 {
+		log.Info("schema is up to date")
+		return
+	}
+
+	for _, res := range results {
+		log.Info("migration applied",
+			slog.String("direction", res.Direction),
+			slog.String("file", filepath.Base(res.Source.Path)),
+			slog.Duration("duration", res.Duration),
+		)
+	}
+}
+
+func printStatus(statuses []*goose.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, filepath.Base(s.Source.Path))
+	}
+	w.Flush()
+}
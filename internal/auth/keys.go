@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrMissingKID = errors.New("token has no kid header")
+)
+
+type SigningKey struct {
+	KID    string
+	Method jwt.SigningMethod
+
+	signKey   any
+	verifyKey any
+}
+
+// KeySet signs tokens with the active key and verifies them with any configured key,
+// so keys can be rotated without invalidating tokens that are still in flight.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	order  []string
+}
+
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	const op = "auth.NewKeySet"
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+
+	for _, kc := range cfg.Keys {
+		if kc.KID == "" {
+			return nil, fmt.Errorf("%s: signing key without kid", op)
+		}
+		if _, ok := ks.keys[kc.KID]; ok {
+			return nil, fmt.Errorf("%s: duplicate kid %q", op, kc.KID)
+		}
+
+		key, err := loadSigningKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, kc.KID, err)
+		}
+
+		ks.keys[key.KID] = key
+		ks.order = append(ks.order, key.KID)
+	}
+
+	active, ok := ks.keys[cfg.ActiveKID]
+	if !ok {
+		return nil, fmt.Errorf("%s: active kid %q is not configured", op, cfg.ActiveKID)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+func loadSigningKey(kc config.SigningKeyConfig) (*SigningKey, error) {
+	switch kc.Algorithm {
+	case AlgorithmHS256:
+		secret := os.Getenv(kc.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("environment variable %q is empty", kc.SecretEnv)
+		}
+		return &SigningKey{
+			KID:       kc.KID,
+			Method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}, nil
+
+	case AlgorithmRS256:
+		pem, err := os.ReadFile(kc.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{
+			KID:       kc.KID,
+			Method:    jwt.SigningMethodRS256,
+			signKey:   key,
+			verifyKey: &key.PublicKey,
+		}, nil
+
+	case AlgorithmEdDSA:
+		pem, err := os.ReadFile(kc.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 private key")
+		}
+		return &SigningKey{
+			KID:       kc.KID,
+			Method:    jwt.SigningMethodEdDSA,
+			signKey:   edKey,
+			verifyKey: edKey.Public(),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.KID
+
+	return token.SignedString(ks.active.signKey)
+}
+
+// Keyfunc resolves the verification key from the token's kid header.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, ErrMissingKID
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// never let the token choose the algorithm for a key
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+
+	return key.verifyKey, nil
+}
+
+// Algorithms lists the algorithms of all configured keys.
+func (ks *KeySet) Algorithms() []string {
+	var algs []string
+	seen := make(map[string]bool)
+	for _, kid := range ks.order {
+		alg := ks.keys[kid].Method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+
+	return algs
+}
+
+// PublicKeys returns the asymmetric keys that other services may verify tokens with.
+func (ks *KeySet) PublicKeys() []*SigningKey {
+	var keys []*SigningKey
+	for _, kid := range ks.order {
+		key := ks.keys[kid]
+		switch key.verifyKey.(type) {
+		case *rsa.PublicKey, ed25519.PublicKey:
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
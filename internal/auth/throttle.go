@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"sync"
+	"time"
+)
+
+// inFlightWait is the wait suggested to an attempt turned away because
+// another one for the same account is still being checked.
+const inFlightWait = time.Second
+
+type attempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	locked       bool
+	// reservation is the attempt currently being checked for an account,
+	// zero if none. IPs are not reserved.
+	reservation uint64
+}
+
+// LoginThrottle tracks failed logins per account and per client IP. Every
+// failure doubles the delay before the next attempt is accepted, and too many
+// failures for one account lock it temporarily. Accounts are tracked by the
+// submitted username whether it exists or not, so a lockout reveals nothing.
+// Only one attempt per account is checked at a time, so parallel requests
+// can't get more guesses at a password past the backoff than sequential ones.
+// IPs only count failures, users behind a shared proxy or NAT can still log
+// in side by side.
+type LoginThrottle struct {
+	base      time.Duration
+	max       time.Duration
+	threshold int
+	lockout   time.Duration
+	window    time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*attempts
+	lastPrune time.Time
+	lastID    uint64
+}
+
+func NewLoginThrottle(cfg config.AuthConfig) *LoginThrottle {
+	return &LoginThrottle{
+		base:      cfg.LoginBackoffBase,
+		max:       cfg.LoginBackoffMax,
+		threshold: cfg.LockoutThreshold,
+		lockout:   cfg.LockoutDuration,
+		window:    cfg.FailureWindow,
+		entries:   make(map[string]*attempts),
+		lastPrune: time.Now(),
+	}
+}
+
+// Attempt is a login attempt admitted by Begin. It holds its account until it
+// is settled with Success or Failure, or given up with Release.
+// Only the first of these has an effect, so Release can be deferred.
+type Attempt struct {
+	t       *LoginThrottle
+	account string
+	ip      string
+	id      uint64
+	settled bool
+}
+
+// Begin admits an attempt for the account from ip, or returns nil and how long
+// the caller has to wait while a backoff, a lockout or another attempt for
+// the same account is in the way.
+func (t *LoginThrottle) Begin(account, ip string) (*Attempt, time.Duration) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wait time.Duration
+	for _, key := range []string{accountKey(account), ipKey(ip)} {
+		e := t.entry(key, now)
+		if e == nil {
+			continue
+		}
+		if e.blockedUntil.After(now) {
+			wait = max(wait, e.blockedUntil.Sub(now))
+		}
+		if e.reservation != 0 {
+			wait = max(wait, inFlightWait)
+		}
+	}
+	if wait > 0 {
+		return nil, wait
+	}
+
+	key := accountKey(account)
+	e := t.entry(key, now)
+	if e == nil {
+		e = &attempts{}
+		t.entries[key] = e
+	}
+	t.lastID++
+	e.reservation = t.lastID
+
+	return &Attempt{t: t, account: account, ip: ip, id: t.lastID}, 0
+}
+
+// Failure records the attempt as failed and reports whether it locked the account.
+func (a *Attempt) Failure() bool {
+	if a.settled {
+		return false
+	}
+	a.settled = true
+
+	t := a.t
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.release(a, now)
+	t.prune(now)
+
+	t.fail(ipKey(a.ip), now)
+	e := t.fail(accountKey(a.account), now)
+
+	if !e.locked && t.threshold > 0 && e.failures >= t.threshold {
+		e.locked = true
+		e.blockedUntil = now.Add(t.lockout)
+		return true
+	}
+
+	return false
+}
+
+// Success forgets the account's failures. The IP keeps its history, otherwise
+// logging into an own account would reset the counter for guessing others.
+func (a *Attempt) Success() {
+	if a.settled {
+		return
+	}
+	a.settled = true
+
+	a.t.mu.Lock()
+	defer a.t.mu.Unlock()
+
+	a.t.release(a, time.Now())
+	delete(a.t.entries, accountKey(a.account))
+}
+
+// Release gives the attempt up without counting it, for checks that ended
+// before the credentials were judged.
+func (a *Attempt) Release() {
+	if a.settled {
+		return
+	}
+	a.settled = true
+
+	a.t.mu.Lock()
+	defer a.t.mu.Unlock()
+
+	a.t.release(a, time.Now())
+}
+
+// release drops the attempt's reservation, must be called with the lock held.
+func (t *LoginThrottle) release(a *Attempt, now time.Time) {
+	key := accountKey(a.account)
+	e, ok := t.entries[key]
+	if !ok || e.reservation != a.id {
+		return
+	}
+	e.reservation = 0
+	if t.expired(e, now) {
+		delete(t.entries, key)
+	}
+}
+
+func (t *LoginThrottle) fail(key string, now time.Time) *attempts {
+	e := t.entry(key, now)
+	if e == nil {
+		e = &attempts{}
+		t.entries[key] = e
+	}
+	// a lockout that ran out starts the count over
+	if e.locked && !now.Before(e.blockedUntil) {
+		e.locked = false
+		e.failures = 0
+	}
+
+	e.failures++
+	e.lastFailure = now
+	if !e.locked {
+		e.blockedUntil = now.Add(t.backoff(e.failures))
+	}
+
+	return e
+}
+
+// entry returns the key's attempts unless they are outdated.
+func (t *LoginThrottle) entry(key string, now time.Time) *attempts {
+	e, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	if t.expired(e, now) {
+		delete(t.entries, key)
+		return nil
+	}
+
+	return e
+}
+
+func (t *LoginThrottle) expired(e *attempts, now time.Time) bool {
+	return e.reservation == 0 && now.After(e.blockedUntil) && now.Sub(e.lastFailure) > t.window
+}
+
+func (t *LoginThrottle) backoff(failures int) time.Duration {
+	d := t.base
+	for i := 1; i < failures && d < t.max; i++ {
+		d *= 2
+	}
+
+	return min(d, t.max)
+}
+
+func (t *LoginThrottle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < t.window {
+		return
+	}
+	t.lastPrune = now
+
+	for key, e := range t.entries {
+		if t.expired(e, now) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+func accountKey(account string) string {
+	return "account:" + account
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestThrottle() *LoginThrottle {
+	return NewLoginThrottle(config.AuthConfig{
+		LoginBackoffBase: time.Minute,
+		LoginBackoffMax:  time.Hour,
+		LockoutThreshold: 10,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    time.Hour,
+	})
+}
+
+// guess runs n parallel failing attempts, from the same IP or from one IP
+// each, and returns how many were admitted.
+func guess(t *LoginThrottle, n int, sameIP bool) int {
+	var (
+		admitted atomic.Int32
+		start    = make(chan struct{})
+		wg       sync.WaitGroup
+	)
+	for i := range n {
+		ip := "10.0.0.1"
+		if !sameIP {
+			ip = fmt.Sprintf("10.0.1.%d", i)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			attempt, wait := t.Begin("alice", ip)
+			if attempt == nil {
+				if wait <= 0 {
+					panic("turned away without a wait")
+				}
+				return
+			}
+			admitted.Add(1)
+			// the password check takes a while, others arrive meanwhile
+			time.Sleep(10 * time.Millisecond)
+			attempt.Failure()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	return int(admitted.Load())
+}
+
+func TestLoginThrottleParallelAttempts(t *testing.T) {
+	for _, sameIP := range []bool{true, false} {
+		t.Run(fmt.Sprintf("sameIP=%v", sameIP), func(t *testing.T) {
+			throttle := newTestThrottle()
+
+			if got := guess(throttle, 30, sameIP); got != 1 {
+				t.Fatalf("admitted %d parallel attempts, want 1", got)
+			}
+			// the failure starts the backoff window, nothing gets through it
+			if got := guess(throttle, 30, sameIP); got != 0 {
+				t.Fatalf("admitted %d attempts within the backoff window, want 0", got)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleSettle(t *testing.T) {
+	throttle := newTestThrottle()
+
+	attempt, _ := throttle.Begin("alice", "10.0.0.1")
+	if attempt == nil {
+		t.Fatal("first attempt was turned away")
+	}
+	if other, wait := throttle.Begin("alice", "10.0.0.2"); other != nil || wait != inFlightWait {
+		t.Fatalf("attempt for the same account admitted while one is in flight, wait %v", wait)
+	}
+
+	attempt.Release()
+	// settling twice must not release a reservation taken in between
+	next, _ := throttle.Begin("alice", "10.0.0.2")
+	if next == nil {
+		t.Fatal("attempt turned away after release")
+	}
+	attempt.Failure()
+	if other, _ := throttle.Begin("alice", "10.0.0.3"); other != nil {
+		t.Fatal("second settle released another attempt's reservation")
+	}
+
+	next.Success()
+	if again, _ := throttle.Begin("alice", "10.0.0.3"); again == nil {
+		t.Fatal("attempt turned away after success")
+	}
+}
+
+func TestLoginThrottleSharedIP(t *testing.T) {
+	throttle := newTestThrottle()
+
+	// users behind one proxy log in at the same time
+	for _, account := range []string{"alice", "bob", "carol"} {
+		if attempt, wait := throttle.Begin(account, "10.0.0.1"); attempt == nil {
+			t.Fatalf("%s turned away while others from the same IP are in flight, wait %v", account, wait)
+		}
+	}
+
+	// failures from the IP still count against every account tried from it
+	attempt, _ := throttle.Begin("dave", "10.0.0.2")
+	attempt.Failure()
+	if other, wait := throttle.Begin("erin", "10.0.0.2"); other != nil || wait < 59*time.Second {
+		t.Fatalf("IP backoff not applied to another account, wait %v", wait)
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle := NewLoginThrottle(config.AuthConfig{
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    time.Hour,
+	})
+
+	for i := range 3 {
+		attempt, wait := throttle.Begin("alice", fmt.Sprintf("10.0.0.%d", i))
+		if attempt == nil {
+			t.Fatalf("attempt %d turned away, wait %v", i, wait)
+		}
+		if locked := attempt.Failure(); locked != (i == 2) {
+			t.Fatalf("attempt %d: locked = %v", i, locked)
+		}
+	}
+
+	if _, wait := throttle.Begin("alice", "10.0.0.9"); wait < 59*time.Minute {
+		t.Fatalf("locked account waits %v, want the lockout duration", wait)
+	}
+}
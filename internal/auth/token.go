@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	tokenUseAccess      = "access"
+	tokenUseMFA         = "mfa"
+	tokenUseEmailVerify = "email_verify"
+
+	mfaChallengeTTL = time.Minute * 5
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type TokenIssuer interface {
+	IssueAccessToken(userId int) (string, error)
+	AccessTokenTTL() time.Duration
+	// IssueMFAChallenge returns a token that proves the password step of a two-step login.
+	IssueMFAChallenge(userId int) (string, error)
+	// IssueEmailVerification returns a token for the link that confirms the user owns the email.
+	IssueEmailVerification(userId int, email string) (string, error)
+}
+
+type TokenVerifier interface {
+	VerifyAccessToken(token string) (models.AccessToken, error)
+	VerifyMFAChallenge(token string) (int, error)
+	VerifyEmailVerification(token string) (int, string, error)
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	UserId int `json:"user_id"`
+	// TokenUse keeps a token of one kind from being accepted as another.
+	TokenUse string `json:"token_use"`
+	Email    string `json:"email,omitempty"`
+}
+
+// TokenService issues and verifies access tokens signed with the key set.
+type TokenService struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	ttl      time.Duration
+	leeway   time.Duration
+	emailTTL time.Duration
+}
+
+func NewTokenService(keys *KeySet, cfg config.JWTConfig) *TokenService {
+	return &TokenService{
+		keys:     keys,
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		ttl:      cfg.AccessTokenTTL,
+		leeway:   cfg.Leeway,
+		emailTTL: cfg.EmailVerificationTTL,
+	}
+}
+
+func (s *TokenService) AccessTokenTTL() time.Duration {
+	return s.ttl
+}
+
+func (s *TokenService) IssueAccessToken(userId int) (string, error) {
+	const op = "auth.IssueAccessToken"
+
+	token, err := s.issue(accessClaims{UserId: userId, TokenUse: tokenUseAccess}, s.ttl)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s *TokenService) IssueMFAChallenge(userId int) (string, error) {
+	const op = "auth.IssueMFAChallenge"
+
+	token, err := s.issue(accessClaims{UserId: userId, TokenUse: tokenUseMFA}, mfaChallengeTTL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s *TokenService) IssueEmailVerification(userId int, email string) (string, error) {
+	const op = "auth.IssueEmailVerification"
+
+	claims := accessClaims{UserId: userId, TokenUse: tokenUseEmailVerify, Email: email}
+	token, err := s.issue(claims, s.emailTTL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s *TokenService) issue(claims accessClaims, ttl time.Duration) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Issuer:    s.issuer,
+		Subject:   fmt.Sprint(claims.UserId),
+		Audience:  jwt.ClaimStrings{s.audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	return s.keys.Sign(claims)
+}
+
+func (s *TokenService) VerifyAccessToken(accessToken string) (models.AccessToken, error) {
+	const op = "auth.VerifyAccessToken"
+
+	claims, err := s.parse(accessToken, tokenUseAccess)
+	if err != nil {
+		return models.AccessToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return models.AccessToken{}, fmt.Errorf("%s: %w: missing jti or iat", op, ErrInvalidToken)
+	}
+
+	return models.AccessToken{
+		ID:        claims.ID,
+		UserID:    claims.UserId,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+func (s *TokenService) VerifyMFAChallenge(token string) (int, error) {
+	const op = "auth.VerifyMFAChallenge"
+
+	claims, err := s.parse(token, tokenUseMFA)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims.UserId, nil
+}
+
+func (s *TokenService) VerifyEmailVerification(token string) (int, string, error) {
+	const op = "auth.VerifyEmailVerification"
+
+	claims, err := s.parse(token, tokenUseEmailVerify)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims.UserId, claims.Email, nil
+}
+
+func (s *TokenService) parse(token, use string) (*accessClaims, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, s.keys.Keyfunc, s.parserOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if claims.TokenUse != use {
+		return nil, fmt.Errorf("%w: unexpected token use %q", ErrInvalidToken, claims.TokenUse)
+	}
+
+	return &claims, nil
+}
+
+func (s *TokenService) parserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithValidMethods(s.keys.Algorithms()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,245 @@
+// Package diff compares texts line by line and renders the result as a
+// unified diff.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change.
+const Context = 3
+
+// maxEdits bounds the work spent looking for a minimal diff. Texts differing
+// in more lines than that are shown as removed and added as a whole.
+const maxEdits = 1000
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Line is a line of either text, Delete lines come from the first one and
+// Insert lines from the second.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the edits turning a into b, a shortest sequence as found by
+// Myers' algorithm.
+func Lines(a, b string) []Line {
+	al, bl := split(a), split(b)
+
+	// common ends are cheap to strip and keep the search small
+	pre := 0
+	for pre < len(al) && pre < len(bl) && al[pre] == bl[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(al)-pre && suf < len(bl)-pre && al[len(al)-1-suf] == bl[len(bl)-1-suf] {
+		suf++
+	}
+
+	lines := make([]Line, 0, len(al)+len(bl)-pre-suf)
+	for _, l := range al[:pre] {
+		lines = append(lines, Line{Equal, l})
+	}
+	lines = append(lines, myers(al[pre:len(al)-suf], bl[pre:len(bl)-suf])...)
+	for _, l := range al[len(al)-suf:] {
+		lines = append(lines, Line{Equal, l})
+	}
+
+	return lines
+}
+
+// Unified renders the edits turning a into b as a unified diff with the
+// given file labels. It returns an empty string if the texts are equal.
+func Unified(fromLabel, toLabel, a, b string) string {
+	lines := Lines(a, b)
+	if !slices.ContainsFunc(lines, func(l Line) bool { return l.Op != Equal }) {
+		return ""
+	}
+
+	// line numbers in a and b at every position of lines
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.Op != Insert {
+			aPos[i+1]++
+		}
+		if l.Op != Delete {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].Op == Equal {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		start := max(i-Context, 0)
+		end := i
+		// changes separated by few enough unchanged lines share a hunk
+		for {
+			for end < len(lines) && lines[end].Op != Equal {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].Op == Equal {
+				next++
+			}
+			if next == len(lines) || next-end > 2*Context {
+				break
+			}
+			end = next
+		}
+		stop := min(end+Context, len(lines))
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[stop]), hunkRange(bPos[start], bPos[stop]))
+		for _, l := range lines[start:stop] {
+			switch l.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			}
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+
+		i = stop
+	}
+
+	return sb.String()
+}
+
+// hunkRange formats the lines from (zero based) up to to the way diff -u
+// does, an empty range names the line before it.
+func hunkRange(from, to int) string {
+	switch n := to - from; n {
+	case 0:
+		return fmt.Sprintf("%d,0", from)
+	case 1:
+		return fmt.Sprintf("%d", from+1)
+	default:
+		return fmt.Sprintf("%d,%d", from+1, n)
+	}
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// myers walks the edit graph of a and b breadth first by the number of
+// edits, keeping the furthest point reached on every diagonal, and then
+// backtracks from the end to recover the path.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replace(a, b)
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k = x - y
+	offset := maxEdits + 1
+	v := make([]int, 2*offset+1)
+	// trace[d] holds diagonals -(d-1)..d-1 as they were before step d
+	var trace [][]int
+
+	for d := 0; d <= maxEdits; d++ {
+		if d == 0 {
+			trace = append(trace, nil)
+		} else {
+			trace = append(trace, slices.Clone(v[offset-d+1:offset+d]))
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+
+	return replace(a, b)
+}
+
+func backtrack(a, b []string, trace [][]int) []Line {
+	var lines []Line
+
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, Line{Equal, a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, Line{Insert, b[y]})
+		} else {
+			x--
+			lines = append(lines, Line{Delete, a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		lines = append(lines, Line{Equal, a[x]})
+	}
+
+	slices.Reverse(lines)
+	return lines
+}
+
+// replace shows all of a as removed and all of b as added.
+func replace(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, l := range a {
+		lines = append(lines, Line{Delete, l})
+	}
+	for _, l := range b {
+		lines = append(lines, Line{Insert, l})
+	}
+	return lines
+}
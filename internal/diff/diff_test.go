@@ -0,0 +1,181 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		want    []Line
+		unified string
+	}{
+		{
+			name:    "empty to text",
+			a:       "",
+			b:       "a\nb\n",
+			want:    []Line{{Insert, "a"}, {Insert, "b"}},
+			unified: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "text to empty",
+			a:       "a\nb\n",
+			b:       "",
+			want:    []Line{{Delete, "a"}, {Delete, "b"}},
+			unified: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name:    "single line change",
+			a:       "a\nb\nc\n",
+			b:       "a\nx\nc\n",
+			want:    []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}},
+			unified: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name:    "crlf",
+			a:       "a\r\nb\r\n",
+			b:       "a\r\nc\r\n",
+			want:    []Line{{Equal, "a\r"}, {Delete, "b\r"}, {Insert, "c\r"}},
+			unified: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\r\n-b\r\n+c\r\n",
+		},
+		{
+			// a missing final newline is not a change of its own
+			name: "no trailing newline",
+			a:    "a\nb",
+			b:    "a\nb\n",
+			want: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name:    "no trailing newline with a change",
+			a:       "a\nb",
+			b:       "a\nc",
+			want:    []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "c"}},
+			unified: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lines(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Fatalf("Lines: got %q, want %q", got, tt.want)
+			}
+			if got := Unified("old", "new", tt.a, tt.b); got != tt.unified {
+				t.Fatalf("Unified: got %q, want %q", got, tt.unified)
+			}
+		})
+	}
+}
+
+// TestLinesMaxEdits diffs texts that share a line but differ in more lines
+// than the search allows, they are replaced as a whole.
+func TestLinesMaxEdits(t *testing.T) {
+	var a, b []string
+	for i := range maxEdits {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = slices.Insert(a, maxEdits/2, "shared")
+	b = slices.Insert(b, maxEdits/2, "shared")
+	oldText, newText := strings.Join(a, "\n"), strings.Join(b, "\n")
+
+	lines := Lines(oldText, newText)
+	want := replace(a, b)
+	if !slices.Equal(lines, want) {
+		t.Fatalf("Lines did not fall back to replacing the text, got %d lines", len(lines))
+	}
+	checkRoundTrip(t, oldText, newText)
+}
+
+// TestRoundTrip applies the diffs of random texts and checks that they turn
+// the old text into the new one.
+func TestRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	text := func() string {
+		lines := make([]string, rnd.IntN(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.IntN(4)))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for range 500 {
+		checkRoundTrip(t, text(), text())
+	}
+}
+
+func checkRoundTrip(t *testing.T, a, b string) {
+	t.Helper()
+
+	var from, to []string
+	for _, l := range Lines(a, b) {
+		if l.Op != Insert {
+			from = append(from, l.Text)
+		}
+		if l.Op != Delete {
+			to = append(to, l.Text)
+		}
+	}
+	if !slices.Equal(from, split(a)) || !slices.Equal(to, split(b)) {
+		t.Fatalf("Lines(%q, %q) does not rebuild the texts", a, b)
+	}
+
+	if got := patch(t, a, Unified("old", "new", a, b)); !slices.Equal(got, split(b)) {
+		t.Fatalf("patching %q gave %q, want %q", a, got, split(b))
+	}
+}
+
+// patch applies a unified diff to the lines of a.
+func patch(t *testing.T, a, unified string) []string {
+	t.Helper()
+
+	old := split(a)
+	if unified == "" {
+		return old
+	}
+
+	var out []string
+	pos := 0
+	for _, l := range strings.Split(strings.TrimSuffix(unified, "\n"), "\n")[2:] {
+		switch l[0] {
+		case '@':
+			// "@@ -from,n ...", an empty range names the line before it
+			r := strings.Fields(l)[1][1:]
+			start, n, _ := strings.Cut(r, ",")
+			from, err := strconv.Atoi(start)
+			if err != nil {
+				t.Fatalf("bad hunk header %q", l)
+			}
+			if n != "0" {
+				from--
+			}
+			out = append(out, old[pos:from]...)
+			pos = from
+		case ' ', '-':
+			if pos >= len(old) || old[pos] != l[1:] {
+				t.Fatalf("hunk line %q does not match the old text", l)
+			}
+			if l[0] == ' ' {
+				out = append(out, l[1:])
+			}
+			pos++
+		case '+':
+			out = append(out, l[1:])
+		default:
+			t.Fatalf("unexpected diff line %q", l)
+		}
+	}
+
+	return append(out, old[pos:]...)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// VerifyEmail is the target of the link sent by sendEmailVerification.
+func (h *Handlers) VerifyEmail(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.VerifyEmail"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		userId, email, err := h.verifier.VerifyEmailVerification(r.URL.Query().Get("token"))
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid verification token", sl.Err(err))
+			respondError(w, r, storage.ErrInvalidVerificationToken)
+			return
+		}
+
+		err = h.userRepo.MarkEmailVerified(r.Context(), userId, email)
+		if errors.Is(err, storage.ErrInvalidVerificationToken) {
+			log.InfoContext(r.Context(), "email changed since the link was sent", slog.Int("userId", userId))
+			respondError(w, r, err)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to verify email", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "email verified", slog.Int("userId", userId))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+		})
+	}
+}
+
+func (h *Handlers) ResendEmailVerification(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ResendEmailVerification"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		user, err := h.userRepo.GetUserByID(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if user.Email == "" {
+			log.InfoContext(r.Context(), "user has no email", slog.Int("userId", userId))
+			response.RespondProblem(w, r, http.StatusBadRequest, codeNoEmail, "no email address on the account")
+			return
+		}
+		if user.EmailVerified {
+			log.InfoContext(r.Context(), "email already verified", slog.Int("userId", userId))
+			response.RespondProblem(w, r, http.StatusConflict, codeEmailAlreadyVerified, "email is already verified")
+			return
+		}
+
+		if err = h.sendEmailVerification(user); err != nil {
+			log.ErrorContext(r.Context(), "failed to send email verification", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "email verification sent", slog.Int("userId", userId))
+		response.RespondJSON(w, http.StatusAccepted, map[string]interface{}{
+			"status": "OK",
+		})
+	}
+}
+
+func (h *Handlers) sendEmailVerification(user models.User) error {
+	token, err := h.tokens.IssueEmailVerification(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(h.publicURL, "/") + "/auth/email/verify?token=" + url.QueryEscape(token)
+	return h.notifier.NotifyEmailVerification(user, link)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"net/http"
+)
+
+// Problem codes specific to this API, see pkg/response for the generic ones.
+const (
+	codeUsernameTaken            = "username_taken"
+	codeEmailTaken               = "email_taken"
+	codeEmailRequired            = "email_required"
+	codeEmailNotVerified         = "email_not_verified"
+	codeEmailAlreadyVerified     = "email_already_verified"
+	codeNoEmail                  = "no_email"
+	codeInvalidCredentials       = "invalid_credentials"
+	codeInvalidPassword          = "invalid_password"
+	codeInvalidToken             = "invalid_token"
+	codeTokenRevoked             = "token_revoked"
+	codeInvalidRefreshToken      = "invalid_refresh_token"
+	codeRefreshTokenReused       = "refresh_token_reused"
+	codeInvalidResetToken        = "invalid_reset_token"
+	codeInvalidVerificationToken = "invalid_verification_token"
+	codeMFAAlreadyEnabled        = "mfa_already_enabled"
+	codeMFANotEnrolled           = "mfa_not_enrolled"
+	codeInvalidMFAToken          = "invalid_mfa_token"
+	codeInvalidMFACode           = "invalid_mfa_code"
+	codeMFACodeRequired          = "mfa_code_required"
+	codeLoginThrottled           = "login_throttled"
+	codeTagExists                = "tag_exists"
+	codeInvalidNotebook          = "invalid_notebook"
+	codeNotebookCycle            = "notebook_cycle"
+)
+
+type problemMapping struct {
+	err    error
+	status int
+	code   string
+	detail string
+}
+
+// storageProblems maps storage errors onto what clients get to see. Anything
+// missing here is answered with a bare 500.
+var storageProblems = []problemMapping{
+	{context.Canceled, response.StatusClientClosedRequest, response.CodeRequestCanceled, "request canceled"},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, response.CodeTimeout, "storage did not respond in time"},
+	{storage.ErrNotFound, http.StatusNotFound, response.CodeNotFound, "resource not found"},
+	{storage.ErrAccessDenied, http.StatusForbidden, response.CodeForbidden, ""},
+	{storage.ErrTagExists, http.StatusConflict, codeTagExists, "tag already exists, merge the tags instead"},
+	{storage.ErrInvalidNotebook, http.StatusUnprocessableEntity, codeInvalidNotebook, ""},
+	{storage.ErrNotebookCycle, http.StatusConflict, codeNotebookCycle, ""},
+	{storage.ErrUsernameTaken, http.StatusConflict, codeUsernameTaken, ""},
+	{storage.ErrEmailTaken, http.StatusConflict, codeEmailTaken, ""},
+	{storage.ErrInvalidCredentials, http.StatusUnauthorized, codeInvalidCredentials, ""},
+	{storage.ErrMFAAlreadyEnabled, http.StatusConflict, codeMFAAlreadyEnabled, ""},
+	{storage.ErrMFANotEnrolled, http.StatusBadRequest, codeMFANotEnrolled, ""},
+	{storage.ErrInvalidResetToken, http.StatusBadRequest, codeInvalidResetToken, ""},
+	{storage.ErrInvalidVerificationToken, http.StatusBadRequest, codeInvalidVerificationToken, ""},
+	{storage.ErrInvalidRefreshToken, http.StatusUnauthorized, codeInvalidRefreshToken, ""},
+	{storage.ErrRefreshTokenReused, http.StatusUnauthorized, codeRefreshTokenReused, ""},
+}
+
+// respondError answers with the problem err maps to. The caller logs err, the
+// client only ever sees the sentinel's own message.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	for _, m := range storageProblems {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+
+		detail := m.detail
+		if detail == "" {
+			detail = m.err.Error()
+		}
+		response.RespondProblem(w, r, m.status, m.code, detail)
+		return
+	}
+
+	response.RespondInternalError(w, r)
+}
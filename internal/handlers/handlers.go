@@ -1,15 +1,98 @@
 package handlers
 
-import "github/yusupovkuzs/GoNotesApp/internal/storage/postgres"
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/auth"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/notify"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"log/slog"
+)
+
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, token models.AccessToken) (bool, error)
+	Revoke(ctx context.Context, token models.AccessToken) error
+	RevokeAll(ctx context.Context, userId int) error
+}
+
+// reasonAccountLocked labels lockouts among the auth failures, the other reasons
+// are the problem codes returned to the client.
+const reasonAccountLocked = "account_locked"
+
+// Metrics records security and business events for monitoring.
+type Metrics interface {
+	AuthFailure(reason string)
+	NoteCreated()
+	UserRegistered()
+}
+
+// Deps holds everything the handlers depend on.
+type Deps struct {
+	NoteRepo     storage.NoteRepository
+	TagRepo      storage.TagRepository
+	NotebookRepo storage.NotebookRepository
+	RevisionRepo storage.RevisionRepository
+	UserRepo     storage.UserRepository
+	RefreshRepo  storage.RefreshTokenRepository
+	MFARepo      storage.MFARepository
+	ResetRepo    storage.PasswordResetRepository
+	Notifier     notify.Notifier
+	Tokens       auth.TokenIssuer
+	Verifier     auth.TokenVerifier
+	Revocations  RevocationChecker
+	Keys         *auth.KeySet
+	Throttle     *auth.LoginThrottle
+	// AuditLog receives security relevant events such as account lockouts.
+	AuditLog *slog.Logger
+	Metrics  Metrics
+
+	// PublicURL is the base for links sent to users.
+	PublicURL            string
+	RequireVerifiedEmail bool
+}
 
 type Handlers struct {
-	noteRepo *postgres.NoteRepoPostgres
-	userRepo *postgres.UserRepoPostgres
+	noteRepo     storage.NoteRepository
+	tagRepo      storage.TagRepository
+	notebookRepo storage.NotebookRepository
+	revisionRepo storage.RevisionRepository
+	userRepo     storage.UserRepository
+	refreshRepo  storage.RefreshTokenRepository
+	mfaRepo      storage.MFARepository
+	resetRepo    storage.PasswordResetRepository
+	notifier     notify.Notifier
+	tokens       auth.TokenIssuer
+	verifier     auth.TokenVerifier
+	revocations  RevocationChecker
+	keys         *auth.KeySet
+	throttle     *auth.LoginThrottle
+	audit        *slog.Logger
+	metrics      Metrics
+
+	publicURL            string
+	requireVerifiedEmail bool
 }
 
-func NewHandlers(noteRepo *postgres.NoteRepoPostgres, userRepo *postgres.UserRepoPostgres) *Handlers {
+func NewHandlers(deps Deps) *Handlers {
 	return &Handlers{
-		noteRepo: noteRepo,
-		userRepo: userRepo,
+		noteRepo:     deps.NoteRepo,
+		tagRepo:      deps.TagRepo,
+		notebookRepo: deps.NotebookRepo,
+		revisionRepo: deps.RevisionRepo,
+		userRepo:     deps.UserRepo,
+		refreshRepo:  deps.RefreshRepo,
+		mfaRepo:      deps.MFARepo,
+		resetRepo:    deps.ResetRepo,
+		notifier:     deps.Notifier,
+		tokens:       deps.Tokens,
+		verifier:     deps.Verifier,
+		revocations:  deps.Revocations,
+		keys:         deps.Keys,
+		throttle:     deps.Throttle,
+		audit:        deps.AuditLog,
+		metrics:      deps.Metrics,
+
+		publicURL:            deps.PublicURL,
+		requireVerifiedEmail: deps.RequireVerifiedEmail,
 	}
 }
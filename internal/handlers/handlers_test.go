@@ -0,0 +1,568 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/auth"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/internal/handlers"
+	"github/yusupovkuzs/GoNotesApp/internal/notify"
+	"github/yusupovkuzs/GoNotesApp/internal/revocation"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/memory"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/storagetest"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const password = "correct horse 1"
+
+type nopMetrics struct{}
+
+func (nopMetrics) AuthFailure(string) {}
+func (nopMetrics) NoteCreated()       {}
+func (nopMetrics) UserRegistered()    {}
+
+// newServer serves the API routes the tests need on top of the in-memory
+// backend.
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	t.Setenv("TEST_JWT_SECRET", "test secret that is long enough")
+	jwtCfg := config.JWTConfig{
+		Issuer:         "notes-app",
+		Audience:       "notes-app",
+		AccessTokenTTL: 15 * time.Minute,
+		ActiveKID:      "test",
+		Keys:           []config.SigningKeyConfig{{KID: "test", Algorithm: auth.AlgorithmHS256, SecretEnv: "TEST_JWT_SECRET"}},
+	}
+	keys, err := auth.NewKeySet(jwtCfg)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	tokens := auth.NewTokenService(keys, jwtCfg)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := memory.NewDB()
+	h := handlers.NewHandlers(handlers.Deps{
+		NoteRepo:     memory.NewNoteRepoMemory(db, storagetest.MaxRevisions),
+		TagRepo:      memory.NewTagRepoMemory(db),
+		NotebookRepo: memory.NewNotebookRepoMemory(db),
+		RevisionRepo: memory.NewRevisionRepoMemory(db),
+		UserRepo:     memory.NewUserRepoMemory(db, storagetest.Hasher()),
+		RefreshRepo:  memory.NewRefreshTokenRepoMemory(db, 24*time.Hour),
+		MFARepo:      memory.NewMFARepoMemory(db),
+		ResetRepo:    memory.NewPasswordResetRepoMemory(db, time.Hour),
+		Notifier:     notify.NewLogNotifier(log),
+		Tokens:       tokens,
+		Verifier:     tokens,
+		Revocations:  revocation.NewChecker(memory.NewRevocationRepoMemory(db), 30*time.Second),
+		Keys:         keys,
+		Throttle: auth.NewLoginThrottle(config.AuthConfig{
+			LoginBackoffBase: time.Minute,
+			LoginBackoffMax:  time.Hour,
+			LockoutThreshold: 10,
+			LockoutDuration:  time.Hour,
+			FailureWindow:    time.Hour,
+		}),
+		AuditLog: log,
+		Metrics:  nopMetrics{},
+	})
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Route("/auth", func(r chi.Router) {
+		r.Post("/register", h.Register(log))
+		r.Post("/login", h.Login(log))
+		r.Post("/refresh", h.Refresh(log))
+		r.With(h.UserIdentity(log)).Post("/logout", h.Logout(log))
+	})
+	router.Route("/users", func(r chi.Router) {
+		r.Use(h.UserIdentity(log))
+		r.Post("/notes", h.CreateNote(log))
+		r.Get("/notes", h.GetAllNotes(log))
+		r.Get("/notes/search", h.SearchNotes(log))
+		r.Get("/notes/{note_id}", h.GetNote(log))
+		r.Put("/notes/{note_id}", h.UpdateNote(log))
+		r.Delete("/notes/{note_id}", h.DeleteNote(log))
+		r.Get("/notes/{note_id}/revisions", h.GetRevisions(log))
+		r.Get("/trash", h.GetTrash(log))
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type result struct {
+	status      int
+	contentType string
+	body        map[string]any
+}
+
+// do sends body as JSON, with token as the bearer token unless it is empty.
+func do(t *testing.T, srv *httptest.Server, method, path, token string, body any) result {
+	t.Helper()
+
+	res, err := send(srv, method, path, token, body)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return res
+}
+
+// send is do for goroutines other than the test's own.
+func send(srv *httptest.Server, method, path, token string, body any) (result, error) {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return result{}, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		return result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		return result{}, err
+	}
+	defer resp.Body.Close()
+
+	res := result{status: resp.StatusCode, contentType: resp.Header.Get("Content-Type")}
+	if err = json.NewDecoder(resp.Body).Decode(&res.body); err != nil {
+		return result{}, fmt.Errorf("decode response: %w", err)
+	}
+	return res, nil
+}
+
+func (res result) want(t *testing.T, status int) result {
+	t.Helper()
+
+	if res.status != status {
+		t.Fatalf("got status %d, want %d: %v", res.status, status, res.body)
+	}
+	return res
+}
+
+// wantProblem checks for an application/problem+json answer with code.
+func (res result) wantProblem(t *testing.T, status int, code string) result {
+	t.Helper()
+
+	res.want(t, status)
+	if res.contentType != response.ContentTypeProblem {
+		t.Fatalf("got content type %q, want %q", res.contentType, response.ContentTypeProblem)
+	}
+	if res.body["code"] != code || res.body["status"] != float64(status) {
+		t.Fatalf("got problem %v, want code %q", res.body, code)
+	}
+	return res
+}
+
+func (res result) string(t *testing.T, key string) string {
+	t.Helper()
+
+	s, ok := res.body[key].(string)
+	if !ok || s == "" {
+		t.Fatalf("response has no %s: %v", key, res.body)
+	}
+	return s
+}
+
+func (res result) int(t *testing.T, key string) int {
+	t.Helper()
+
+	n, ok := res.body[key].(float64)
+	if !ok {
+		t.Fatalf("response has no %s: %v", key, res.body)
+	}
+	return int(n)
+}
+
+// list returns the array under key, an empty list may come as null.
+func (res result) list(t *testing.T, key string) []any {
+	t.Helper()
+
+	v, ok := res.body[key]
+	if !ok {
+		t.Fatalf("response has no %s: %v", key, res.body)
+	}
+	items, _ := v.([]any)
+	return items
+}
+
+// withQuery adds query to the query string of path.
+func withQuery(path, query string) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + query
+	}
+	return path + "?" + query
+}
+
+func register(t *testing.T, srv *httptest.Server, username string) {
+	t.Helper()
+
+	do(t, srv, http.MethodPost, "/auth/register", "", map[string]string{
+		"username": username,
+		"password": password,
+	}).want(t, http.StatusCreated)
+}
+
+// login registers username and returns an access token for it.
+func login(t *testing.T, srv *httptest.Server, username string) string {
+	t.Helper()
+
+	register(t, srv, username)
+	return do(t, srv, http.MethodPost, "/auth/login", "", map[string]string{
+		"username": username,
+		"password": password,
+	}).want(t, http.StatusOK).string(t, "token")
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	srv := newServer(t)
+	register(t, srv, "alice")
+
+	do(t, srv, http.MethodPost, "/auth/register", "", map[string]string{"username": "alice", "password": password}).
+		wantProblem(t, http.StatusConflict, "username_taken")
+
+	do(t, srv, http.MethodPost, "/auth/login", "", map[string]string{"username": "alice", "password": "wrong password 1"}).
+		wantProblem(t, http.StatusUnauthorized, "invalid_credentials")
+	// the failure starts a backoff, even the right password has to wait
+	res := do(t, srv, http.MethodPost, "/auth/login", "", map[string]string{"username": "alice", "password": password}).
+		wantProblem(t, http.StatusTooManyRequests, "login_throttled")
+	if res.body["detail"] == "" {
+		t.Fatalf("throttled login without detail: %v", res.body)
+	}
+
+	srv = newServer(t)
+	register(t, srv, "bob")
+	res = do(t, srv, http.MethodPost, "/auth/login", "", map[string]string{"username": "bob", "password": password}).
+		want(t, http.StatusOK)
+	token, refresh := res.string(t, "token"), res.string(t, "refresh_token")
+	if res.int(t, "expires_in") != 900 {
+		t.Fatalf("got expires_in %v, want 900", res.body["expires_in"])
+	}
+
+	do(t, srv, http.MethodGet, "/users/notes", token, nil).want(t, http.StatusOK)
+
+	res = do(t, srv, http.MethodPost, "/auth/refresh", "", map[string]string{"refresh_token": refresh}).
+		want(t, http.StatusOK)
+	rotated := res.string(t, "refresh_token")
+	if rotated == refresh {
+		t.Fatal("refresh token was not rotated")
+	}
+	do(t, srv, http.MethodGet, "/users/notes", res.string(t, "token"), nil).want(t, http.StatusOK)
+
+	// presenting a rotated token again revokes the whole family
+	do(t, srv, http.MethodPost, "/auth/refresh", "", map[string]string{"refresh_token": refresh}).
+		wantProblem(t, http.StatusUnauthorized, "refresh_token_reused")
+	do(t, srv, http.MethodPost, "/auth/refresh", "", map[string]string{"refresh_token": rotated}).
+		wantProblem(t, http.StatusUnauthorized, "invalid_refresh_token")
+}
+
+func TestLongestPassword(t *testing.T) {
+	srv := newServer(t)
+	longest := strings.Repeat("é", 35) + "a1"
+
+	do(t, srv, http.MethodPost, "/auth/register", "", map[string]string{"username": "alice", "password": longest}).
+		want(t, http.StatusCreated)
+	do(t, srv, http.MethodPost, "/auth/login", "", map[string]string{"username": "alice", "password": longest}).
+		want(t, http.StatusOK)
+}
+
+func TestLogout(t *testing.T) {
+	srv := newServer(t)
+	token := login(t, srv, "alice")
+
+	do(t, srv, http.MethodPost, "/auth/logout", token, nil).want(t, http.StatusOK)
+	do(t, srv, http.MethodGet, "/users/notes", token, nil).
+		wantProblem(t, http.StatusUnauthorized, "token_revoked")
+}
+
+func TestAuthenticationRequired(t *testing.T) {
+	srv := newServer(t)
+
+	do(t, srv, http.MethodGet, "/users/notes", "", nil).
+		wantProblem(t, http.StatusUnauthorized, response.CodeUnauthorized)
+	do(t, srv, http.MethodGet, "/users/notes", "not-a-jwt", nil).
+		wantProblem(t, http.StatusUnauthorized, "invalid_token")
+}
+
+func TestValidation(t *testing.T) {
+	srv := newServer(t)
+	token := login(t, srv, "alice")
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		body   any
+		status int
+		code   string
+		fields []string
+	}{
+		{
+			name:   "malformed json",
+			path:   "/auth/register",
+			body:   `{"username": `,
+			status: http.StatusBadRequest,
+			code:   response.CodeInvalidBody,
+		},
+		{
+			name:   "missing fields",
+			path:   "/auth/register",
+			body:   map[string]string{},
+			status: http.StatusUnprocessableEntity,
+			code:   response.CodeValidation,
+			fields: []string{"username", "password"},
+		},
+		{
+			name:   "weak password",
+			path:   "/auth/register",
+			body:   map[string]string{"username": "bob", "password": "onlyletters"},
+			status: http.StatusUnprocessableEntity,
+			code:   response.CodeValidation,
+			fields: []string{"password"},
+		},
+		{
+			// short enough in characters, but not in the bytes bcrypt hashes
+			name:   "multibyte password",
+			path:   "/auth/register",
+			body:   map[string]string{"username": "bob", "password": strings.Repeat("é", 36) + "1"},
+			status: http.StatusUnprocessableEntity,
+			code:   response.CodeValidation,
+			fields: []string{"password"},
+		},
+		{
+			name:   "bad username",
+			path:   "/auth/register",
+			body:   map[string]string{"username": "bob smith", "password": password},
+			status: http.StatusUnprocessableEntity,
+			code:   response.CodeValidation,
+			fields: []string{"username"},
+		},
+		{
+			name:   "note without title",
+			path:   "/users/notes",
+			token:  token,
+			body:   map[string]any{"content": "text", "tags": []string{"ok", "not ok"}},
+			status: http.StatusUnprocessableEntity,
+			code:   response.CodeValidation,
+			fields: []string{"title", "tags[1]"},
+		},
+		{
+			name:   "body too large",
+			path:   "/users/notes",
+			token:  token,
+			body:   map[string]string{"title": "big", "content": strings.Repeat("x", 2<<20)},
+			status: http.StatusRequestEntityTooLarge,
+			code:   response.CodePayloadTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := do(t, srv, http.MethodPost, tt.path, tt.token, tt.body).wantProblem(t, tt.status, tt.code)
+
+			errs, _ := res.body["errors"].(map[string]any)
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("got field errors %v, want %v", errs, tt.fields)
+			}
+			for _, f := range tt.fields {
+				if _, ok := errs[f]; !ok {
+					t.Fatalf("no error for field %s: %v", f, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestNoteCRUD(t *testing.T) {
+	srv := newServer(t)
+	token := login(t, srv, "alice")
+
+	id := do(t, srv, http.MethodPost, "/users/notes", token, map[string]any{
+		"title":   "groceries",
+		"content": "milk",
+		"tags":    []string{"Home"},
+	}).want(t, http.StatusCreated).int(t, "noteId")
+	path := fmt.Sprintf("/users/notes/%d", id)
+
+	note := do(t, srv, http.MethodGet, path, token, nil).want(t, http.StatusOK).body["note"].(map[string]any)
+	if note["title"] != "groceries" || note["content"] != "milk" {
+		t.Fatalf("got note %v", note)
+	}
+	if tags := note["tags"].([]any); len(tags) != 1 || tags[0] != "home" {
+		t.Fatalf("tags were not normalized: %v", tags)
+	}
+
+	do(t, srv, http.MethodPut, path, token, map[string]string{"content": "milk, eggs"}).want(t, http.StatusOK)
+	note = do(t, srv, http.MethodGet, path, token, nil).want(t, http.StatusOK).body["note"].(map[string]any)
+	if note["title"] != "groceries" || note["content"] != "milk, eggs" {
+		t.Fatalf("got note %v after update", note)
+	}
+	revisions := do(t, srv, http.MethodGet, path+"/revisions", token, nil).want(t, http.StatusOK).list(t, "revisions")
+	if len(revisions) != 1 {
+		t.Fatalf("got %d revisions after an update, want 1", len(revisions))
+	}
+
+	notes := do(t, srv, http.MethodGet, "/users/notes", token, nil).want(t, http.StatusOK).list(t, "notes")
+	if len(notes) != 1 {
+		t.Fatalf("got %d notes, want 1", len(notes))
+	}
+
+	do(t, srv, http.MethodDelete, path, token, nil).want(t, http.StatusOK)
+	do(t, srv, http.MethodGet, path, token, nil).wantProblem(t, http.StatusNotFound, response.CodeNotFound)
+	do(t, srv, http.MethodDelete, path, token, nil).wantProblem(t, http.StatusNotFound, response.CodeNotFound)
+
+	for _, bad := range []string{"abc", "0", "-1"} {
+		do(t, srv, http.MethodGet, "/users/notes/"+bad, token, nil).wantProblem(t, http.StatusBadRequest, response.CodeBadRequest)
+		do(t, srv, http.MethodPut, "/users/notes/"+bad, token, map[string]string{"title": "x"}).wantProblem(t, http.StatusBadRequest, response.CodeBadRequest)
+		do(t, srv, http.MethodDelete, "/users/notes/"+bad, token, nil).wantProblem(t, http.StatusBadRequest, response.CodeBadRequest)
+	}
+}
+
+func TestNoteOwnership(t *testing.T) {
+	srv := newServer(t)
+	alice, bob := login(t, srv, "alice"), login(t, srv, "bob")
+
+	id := do(t, srv, http.MethodPost, "/users/notes", alice, map[string]string{"title": "diary"}).
+		want(t, http.StatusCreated).int(t, "noteId")
+	path := fmt.Sprintf("/users/notes/%d", id)
+
+	do(t, srv, http.MethodGet, path, bob, nil).wantProblem(t, http.StatusForbidden, response.CodeForbidden)
+	do(t, srv, http.MethodPut, path, bob, map[string]string{"title": "mine"}).wantProblem(t, http.StatusForbidden, response.CodeForbidden)
+	do(t, srv, http.MethodDelete, path, bob, nil).wantProblem(t, http.StatusForbidden, response.CodeForbidden)
+	do(t, srv, http.MethodGet, path+"/revisions", bob, nil).wantProblem(t, http.StatusForbidden, response.CodeForbidden)
+
+	if notes := do(t, srv, http.MethodGet, "/users/notes", bob, nil).want(t, http.StatusOK).list(t, "notes"); len(notes) != 0 {
+		t.Fatalf("bob sees %d of alice's notes", len(notes))
+	}
+	note := do(t, srv, http.MethodGet, path, alice, nil).want(t, http.StatusOK).body["note"].(map[string]any)
+	if note["title"] != "diary" {
+		t.Fatalf("note changed by another user: %v", note)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	srv := newServer(t)
+	token := login(t, srv, "alice")
+	for i := range 6 {
+		id := do(t, srv, http.MethodPost, "/users/notes", token, map[string]string{"title": fmt.Sprintf("note %d", i)}).
+			want(t, http.StatusCreated).int(t, "noteId")
+		if i%2 == 1 {
+			do(t, srv, http.MethodDelete, fmt.Sprintf("/users/notes/%d", id), token, nil).want(t, http.StatusOK)
+		}
+	}
+	revised := do(t, srv, http.MethodPost, "/users/notes", token, map[string]string{"title": "revised"}).
+		want(t, http.StatusCreated).int(t, "noteId")
+	for _, content := range []string{"one", "two"} {
+		do(t, srv, http.MethodPut, fmt.Sprintf("/users/notes/%d", revised), token, map[string]string{"content": content}).
+			want(t, http.StatusOK)
+	}
+
+	for _, tt := range []struct {
+		path, key string
+	}{
+		{"/users/notes", "notes"},
+		{"/users/notes/search?q=note", "notes"},
+		{"/users/trash", "notes"},
+		{fmt.Sprintf("/users/notes/%d/revisions", revised), "revisions"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := do(t, srv, http.MethodGet, withQuery(tt.path, "limit=1&offset=1"), token, nil).want(t, http.StatusOK).list(t, tt.key); len(got) != 1 {
+				t.Fatalf("got %d results with limit=1", len(got))
+			}
+			do(t, srv, http.MethodGet, withQuery(tt.path, "limit=1000"), token, nil).want(t, http.StatusOK)
+
+			for _, query := range []string{"limit=abc", "limit=0", "limit=-1", "offset=-1", "offset=x"} {
+				do(t, srv, http.MethodGet, withQuery(tt.path, query), token, nil).wantProblem(t, http.StatusBadRequest, response.CodeBadRequest)
+			}
+		})
+	}
+}
+
+// TestParallelRequests is meant for the race detector, it hits the shared
+// in-memory backend and the login throttle from many goroutines.
+func TestParallelRequests(t *testing.T) {
+	srv := newServer(t)
+	token := login(t, srv, "alice")
+	register(t, srv, "bob")
+
+	const n = 20
+	statuses := make(chan int, 3*n)
+	errs := make(chan error, 3*n)
+	var wg sync.WaitGroup
+	request := func(method, path, token string, body any) {
+		res, err := send(srv, method, path, token, body)
+		if err != nil {
+			errs <- err
+			return
+		}
+		statuses <- res.status
+	}
+	for i := range n {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+
+			request(http.MethodPost, "/users/notes", token, map[string]string{"title": fmt.Sprintf("note %d", i)})
+			request(http.MethodGet, "/users/notes?limit=100", token, nil)
+		}()
+		go func() {
+			defer wg.Done()
+
+			request(http.MethodPost, "/auth/login", "", map[string]string{"username": "bob", "password": "wrong password 1"})
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+	counts := map[int]int{}
+	for s := range statuses {
+		counts[s]++
+	}
+	want := map[int]int{
+		http.StatusCreated: n,
+		http.StatusOK:      n,
+		// one guess gets checked, the others wait for the backoff
+		http.StatusUnauthorized:    1,
+		http.StatusTooManyRequests: n - 1,
+	}
+	for status, count := range want {
+		if counts[status] != count {
+			t.Fatalf("got statuses %v, want %v", counts, want)
+		}
+	}
+
+	notes := do(t, srv, http.MethodGet, "/users/notes?limit=100", token, nil).want(t, http.StatusOK).list(t, "notes")
+	if len(notes) != n {
+		t.Fatalf("got %d notes, want %d", len(notes), n)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func (h *Handlers) JWKS(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.JWKS"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		set := h.keys.JWKS()
+
+		log.DebugContext(r.Context(), "serving jwks", slog.Int("keys", len(set.Keys)))
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		response.RespondJSON(w, http.StatusOK, set)
+	}
+}
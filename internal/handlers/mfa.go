@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"github/yusupovkuzs/GoNotesApp/internal/auth"
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// EnrollTOTP generates a new secret. 2FA stays off until ConfirmTOTP verifies a first code.
+func (h *Handlers) EnrollTOTP(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.EnrollTOTP"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		user, err := h.userRepo.GetUserByID(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate totp secret", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		err = h.mfaRepo.SetPendingTOTPSecret(r.Context(), userId, secret)
+		if errors.Is(err, storage.ErrMFAAlreadyEnabled) {
+			log.InfoContext(r.Context(), "2fa already enabled", slog.Int("userId", userId))
+			respondError(w, r, err)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to store totp secret", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "totp enrollment started", slog.Int("userId", userId))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":      "OK",
+			"secret":      secret,
+			"otpauth_uri": auth.TOTPURI(user.Username, secret),
+		})
+	}
+}
+
+type totpCodeInput struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// ConfirmTOTP enables 2FA and returns the recovery codes, the only time they are shown.
+func (h *Handlers) ConfirmTOTP(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ConfirmTOTP"
+
+		var input totpCodeInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		totp, err := h.mfaRepo.GetTOTP(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get totp", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if totp.Enabled {
+			log.InfoContext(r.Context(), "2fa already enabled", slog.Int("userId", userId))
+			respondError(w, r, storage.ErrMFAAlreadyEnabled)
+			return
+		}
+		if totp.Secret == "" {
+			log.InfoContext(r.Context(), "2fa not enrolled", slog.Int("userId", userId))
+			respondError(w, r, storage.ErrMFANotEnrolled)
+			return
+		}
+
+		step, ok := auth.ValidateTOTP(totp.Secret, input.Code, time.Now())
+		if !ok {
+			log.InfoContext(r.Context(), "invalid totp code", slog.Int("userId", userId))
+			response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidMFACode, "invalid code")
+			return
+		}
+
+		codes, err := auth.GenerateRecoveryCodes()
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate recovery codes", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		normalized := make([]string, 0, len(codes))
+		for _, code := range codes {
+			normalized = append(normalized, auth.NormalizeRecoveryCode(code))
+		}
+
+		err = h.mfaRepo.EnableTOTP(r.Context(), userId, step, normalized)
+		if errors.Is(err, storage.ErrMFAAlreadyEnabled) {
+			log.InfoContext(r.Context(), "2fa already enabled", slog.Int("userId", userId))
+			respondError(w, r, err)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to enable totp", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "2fa enabled", slog.Int("userId", userId))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":         "OK",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+type disableTOTPInput struct {
+	Password string `json:"password" validate:"required"`
+}
+
+func (h *Handlers) DisableTOTP(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.DisableTOTP"
+
+		var input disableTOTPInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		err = h.userRepo.VerifyPassword(r.Context(), userId, input.Password)
+		if errors.Is(err, storage.ErrInvalidCredentials) {
+			log.InfoContext(r.Context(), "invalid password", slog.Int("userId", userId))
+			response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidPassword, "invalid password")
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to verify password", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.mfaRepo.DisableTOTP(r.Context(), userId); err != nil {
+			log.ErrorContext(r.Context(), "failed to disable totp", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "2fa disabled", slog.Int("userId", userId))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+		})
+	}
+}
+
+type loginMFAInput struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// LoginMFA completes a two-step login with either a TOTP code or a recovery code.
+func (h *Handlers) LoginMFA(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.LoginMFA"
+
+		var input loginMFAInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := h.verifier.VerifyMFAChallenge(input.MFAToken)
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid mfa token", sl.Err(err))
+			h.metrics.AuthFailure(codeInvalidMFAToken)
+			response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidMFAToken, "invalid mfa token")
+			return
+		}
+
+		user, err := h.userRepo.GetUserByID(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		// code guesses count against the same account as password guesses
+		ip := clientIP(r)
+		attempt, wait := h.throttle.Begin(user.Username, ip)
+		if attempt == nil {
+			log.InfoContext(r.Context(), "mfa login throttled", slog.Int("userId", userId), slog.String("ip", ip))
+			h.metrics.AuthFailure(codeLoginThrottled)
+			respondThrottled(w, r, wait)
+			return
+		}
+		defer attempt.Release()
+
+		var ok bool
+		switch {
+		case input.Code != "":
+			var totp models.TOTP
+			totp, err = h.mfaRepo.GetTOTP(r.Context(), userId)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to get totp", sl.Err(err))
+				respondError(w, r, err)
+				return
+			}
+			if !totp.Enabled {
+				log.InfoContext(r.Context(), "2fa not enabled", slog.Int("userId", userId))
+				response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidMFAToken, "invalid mfa token")
+				return
+			}
+
+			var step int64
+			if step, ok = auth.ValidateTOTP(totp.Secret, input.Code, time.Now()); ok {
+				// a code can't be replayed within its validity window
+				ok, err = h.mfaRepo.UseTOTPStep(r.Context(), userId, step)
+			}
+		case input.RecoveryCode != "":
+			ok, err = h.mfaRepo.UseRecoveryCode(r.Context(), userId, auth.NormalizeRecoveryCode(input.RecoveryCode))
+		default:
+			log.InfoContext(r.Context(), "no code provided")
+			response.RespondProblem(w, r, http.StatusBadRequest, codeMFACodeRequired, "code or recovery_code is required")
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to verify code", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if !ok {
+			log.InfoContext(r.Context(), "invalid mfa code", slog.Int("userId", userId))
+			h.loginFailed(r, attempt, user.Username, ip, codeInvalidMFACode)
+			response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidMFACode, "invalid code")
+			return
+		}
+
+		attempt.Success()
+		h.issueTokens(w, r, log, userId, "")
+	}
+}
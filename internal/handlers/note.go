@@ -1,57 +1,55 @@
 package handlers
 
 import (
-	"database/sql"
 	"errors"
 	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
 	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/search"
 	"github/yusupovkuzs/GoNotesApp/internal/storage"
 	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
 	"github/yusupovkuzs/GoNotesApp/pkg/response"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"strings"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
 )
 
 func (h *Handlers) CreateNote(log *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.CreateNote"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
 		var input models.Note
-		err := render.DecodeJSON(r.Body, &input)
-		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
 			return
 		}
-		log.Info("request body decoded successfully", slog.Any("input", input))
+		log.InfoContext(r.Context(), "request body decoded successfully", slog.Any("input", input))
 
 		userId, err := mw.GetUserID(r)
 		if err != nil {
-			log.Error("failed to get id", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get id", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("user id found", slog.Any("userId", userId))
+		log.InfoContext(r.Context(), "user id found", slog.Any("userId", userId))
 
 		input.UserID = userId
-		id, err := h.noteRepo.CreateNote(input)
+		input.Tags = models.NormalizeTags(input.Tags)
+		id, err := h.noteRepo.CreateNote(r.Context(), input)
 		if err != nil {
-			log.Error("failed to create note", sl.Err(err))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to create note", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("note created successfully", slog.Int("id", id))
+		log.InfoContext(r.Context(), "note created successfully", slog.Int("id", id))
+		h.metrics.NoteCreated()
 		response.RespondJSON(w, http.StatusCreated, map[string]interface{}{
 			"status": "OK",
 			"userId": userId,
@@ -64,49 +62,123 @@ func (h *Handlers) GetAllNotes(log *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.GetAllNotes"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		q := r.URL.Query()
-		limit := 10
-		offset := 0
-		sort := "asc"
-		if v := q.Get("limit"); v != "" {
-			if l, err := strconv.Atoi(v); err == nil {
-				limit = l
-			}
+		filter, ok := noteFilter(w, r, log)
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		log.InfoContext(r.Context(), "user id found", slog.Any("userId", userId))
+
+		notes, err := h.noteRepo.GetAllNotes(r.Context(), userId, filter)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get notes", sl.Err(err))
+			respondError(w, r, err)
+			return
 		}
 
-		if v := q.Get("offset"); v != "" {
-			if o, err := strconv.Atoi(v); err == nil {
-				offset = o
+		log.InfoContext(r.Context(), "notes found", slog.Any("notes", notes))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"notes":  notes,
+		})
+	}
+}
+
+// noteFilter reads paging, sorting and tag filters from the query string. It
+// answers the request itself and reports false if they are invalid.
+func noteFilter(w http.ResponseWriter, r *http.Request, log *slog.Logger) (storage.NoteFilter, bool) {
+	limit, offset, ok := pageParams(w, r, log)
+	if !ok {
+		return storage.NoteFilter{}, false
+	}
+
+	q := r.URL.Query()
+	filter := storage.NoteFilter{Limit: limit, Offset: offset, Sort: "asc"}
+
+	if v := q.Get("sort"); v == "asc" || v == "desc" {
+		filter.Sort = v
+	}
+
+	// tags=a,b keeps notes tagged a or b, match=all only those tagged with both
+	if v := q.Get("tags"); v != "" {
+		tags := models.NormalizeTags(strings.Split(v, ","))
+		for _, t := range tags {
+			if err := validate.Var(t, "min=1,max=50,tag"); err != nil {
+				log.InfoContext(r.Context(), "invalid tag filter", slog.String("tag", t))
+				response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "invalid tag in filter")
+				return storage.NoteFilter{}, false
 			}
 		}
+		filter.Tags = tags
+	}
+
+	switch q.Get("match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "match must be any or all")
+		return storage.NoteFilter{}, false
+	}
+
+	return filter, true
+}
+
+// maxSearchQueryLength keeps search queries to what a person would type.
+const maxSearchQueryLength = 256
+
+func (h *Handlers) SearchNotes(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.SearchNotes"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
 
-		if v := q.Get("sort"); v == "asc" || v == "desc" {
-			sort = v
+		q := r.URL.Query()
+		if len(q.Get("q")) > maxSearchQueryLength {
+			response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "search query too long")
+			return
+		}
+		query := search.Parse(q.Get("q"))
+		if query.Empty() {
+			response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "search query must contain a word")
+			return
+		}
+
+		limit, offset, ok := pageParams(w, r, log)
+		if !ok {
+			return
 		}
 
 		userId, err := mw.GetUserID(r)
 		if err != nil {
-			log.Error("failed to get id", sl.Err(err))
-			render.JSON(w, r, response.Error("failed to get id"))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get id", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("user id found", slog.Any("userId", userId))
 
-		notes, err := h.noteRepo.GetAllNotes(userId, limit, offset, sort)
+		notes, err := h.noteRepo.SearchNotes(r.Context(), userId, query, limit, offset)
 		if err != nil {
-			log.Error("failed to get notes", sl.Err(err))
-			render.JSON(w, r, response.Error("failed to get notes"))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to search notes", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("notes found", slog.Any("notes", notes))
+		log.InfoContext(r.Context(), "notes searched", slog.Int("found", len(notes)))
 		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
 			"status": "OK",
 			"userID": userId,
@@ -119,52 +191,43 @@ func (h *Handlers) GetNote(log *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.GetNote"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		noteId := chi.URLParam(r, "note_id")
-		if noteId == "" {
-			log.Info("no note id provided")
-			response.RespondError(w, http.StatusBadRequest, "no note id provided")
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
 			return
 		}
-
-		noteID, err := strconv.Atoi(noteId)
-		if err != nil {
-			log.Error("failed to convert note id to int", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
-			return
-		}
-		log.Info("note id found", slog.Any("noteId", noteID))
+		log.InfoContext(r.Context(), "note id found", slog.Any("noteId", noteID))
 
 		userId, err := mw.GetUserID(r)
 		if err != nil {
-			log.Error("failed to get user id", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("user id found", slog.Any("userId", userId))
+		log.InfoContext(r.Context(), "user id found", slog.Any("userId", userId))
 
-		note, err := h.noteRepo.GetNote(userId, noteID)
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Error("note not found", sl.Err(err))
-			response.RespondError(w, http.StatusNotFound, err.Error())
+		note, err := h.noteRepo.GetNote(r.Context(), userId, noteID)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.ErrorContext(r.Context(), "note not found", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 		if errors.Is(err, storage.ErrAccessDenied) {
-			log.Info("access denied", sl.Err(err))
-			response.RespondError(w, http.StatusForbidden, err.Error())
+			log.InfoContext(r.Context(), "access denied", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 		if err != nil {
-			log.Error("failed to get note", sl.Err(err))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to get note", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("note found", slog.Any("note", note))
+		log.InfoContext(r.Context(), "note found", slog.Any("note", note))
 		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
 			"status": "OK",
 			"userID": userId,
@@ -177,60 +240,54 @@ func (h *Handlers) UpdateNote(log *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.UpdateNote"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		noteId := chi.URLParam(r, "note_id")
-		if noteId == "" {
-			log.Info("no note id provided")
-			response.RespondError(w, http.StatusBadRequest, "no note id provided")
-			return
-		}
-
-		noteID, err := strconv.Atoi(noteId)
-		if err != nil {
-			log.Error("failed to convert note id to int", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
 			return
 		}
-		log.Info("note id found", slog.Any("noteId", noteID))
+		log.InfoContext(r.Context(), "note id found", slog.Any("noteId", noteID))
 
 		var input models.UpdateNoteInput
-		if err = render.DecodeJSON(r.Body, &input); err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
 			return
 		}
-		log.Info("request body decoded successfully", slog.Any("input", input))
+		log.InfoContext(r.Context(), "request body decoded successfully", slog.Any("input", input))
+		if input.Tags != nil {
+			tags := models.NormalizeTags(*input.Tags)
+			input.Tags = &tags
+		}
 
 		userId, err := mw.GetUserID(r)
 		if err != nil {
-			log.Error("failed to get user id", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("user id found", slog.Any("userId", userId))
+		log.InfoContext(r.Context(), "user id found", slog.Any("userId", userId))
 
-		err = h.noteRepo.UpdateNote(userId, noteID, input)
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Error("note not found", sl.Err(err))
-			response.RespondError(w, http.StatusNotFound, err.Error())
+		err = h.noteRepo.UpdateNote(r.Context(), userId, noteID, input)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.ErrorContext(r.Context(), "note not found", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 		if errors.Is(err, storage.ErrAccessDenied) {
-			log.Info("access denied", sl.Err(err))
-			response.RespondError(w, http.StatusForbidden, err.Error())
+			log.InfoContext(r.Context(), "access denied", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 		if err != nil {
-			log.Error("failed to update note", sl.Err(err))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to update note", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("note updated", slog.Any("note", input))
+		log.InfoContext(r.Context(), "note updated", slog.Any("note", input))
 		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
 			"status": "OK",
 			"userID": userId,
@@ -239,55 +296,90 @@ func (h *Handlers) UpdateNote(log *slog.Logger) http.HandlerFunc {
 	}
 }
 
-func (h *Handlers) DeleteNote(log *slog.Logger) http.HandlerFunc {
+func (h *Handlers) MoveNote(log *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.UpdateNote"
+		const op = "handlers.MoveNote"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		noteId := chi.URLParam(r, "note_id")
-		if noteId == "" {
-			log.Info("no note id provided")
-			response.RespondError(w, http.StatusBadRequest, "no note id provided")
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
 			return
 		}
 
-		noteID, err := strconv.Atoi(noteId)
+		var input models.MoveNoteInput
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
 		if err != nil {
-			log.Error("failed to convert note id to int", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("note id found", slog.Any("noteId", noteId))
+
+		if err = h.noteRepo.MoveNote(r.Context(), userId, noteID, input.NotebookID); err != nil {
+			log.InfoContext(r.Context(), "failed to move note", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "note moved", slog.Int("noteId", noteID), slog.Any("notebookId", input.NotebookID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "OK",
+			"userID":     userId,
+			"noteID":     noteID,
+			"notebookID": input.NotebookID,
+		})
+	}
+}
+
+func (h *Handlers) DeleteNote(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.DeleteNote"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+		log.InfoContext(r.Context(), "note id found", slog.Any("noteId", noteID))
 
 		userId, err := mw.GetUserID(r)
 		if err != nil {
-			log.Error("failed to get user id", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("user id found", slog.Any("userId", userId))
+		log.InfoContext(r.Context(), "user id found", slog.Any("userId", userId))
 
-		err = h.noteRepo.DeleteNote(userId, noteID)
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Error("note not found", sl.Err(err))
-			response.RespondError(w, http.StatusNotFound, err.Error())
+		err = h.noteRepo.DeleteNote(r.Context(), userId, noteID)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.ErrorContext(r.Context(), "note not found", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 		if errors.Is(err, storage.ErrAccessDenied) {
-			log.Info("access denied", sl.Err(err))
-			response.RespondError(w, http.StatusForbidden, err.Error())
+			log.InfoContext(r.Context(), "access denied", sl.Err(err))
+			respondError(w, r, err)
+			return
 		}
 		if err != nil {
-			log.Error("failed to delete note", sl.Err(err))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to delete note", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("note deleted", slog.Any("note", noteId))
+		log.InfoContext(r.Context(), "note moved to trash", slog.Any("note", noteID))
 		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
 			"status": "OK",
 			"userID": userId,
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func (h *Handlers) CreateNotebook(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.CreateNotebook"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var input models.Notebook
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		input.UserID = userId
+		id, err := h.notebookRepo.CreateNotebook(r.Context(), input)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to create notebook", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "notebook created", slog.Int("id", id))
+		response.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"status":     "OK",
+			"userId":     userId,
+			"notebookId": id,
+		})
+	}
+}
+
+// GetNotebooks lists all of the user's notebooks, clients build the tree from their parent ids.
+func (h *Handlers) GetNotebooks(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.GetNotebooks"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		notebooks, err := h.notebookRepo.GetNotebooks(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get notebooks", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    "OK",
+			"userID":    userId,
+			"notebooks": notebooks,
+		})
+	}
+}
+
+func (h *Handlers) GetNotebook(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.GetNotebook"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		notebookID, ok := idParam(w, r, log, "notebook_id")
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		notebook, err := h.notebookRepo.GetNotebook(r.Context(), userId, notebookID)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to get notebook", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "OK",
+			"userID":   userId,
+			"notebook": notebook,
+		})
+	}
+}
+
+// GetNotebookNotes lists the notes filed in a notebook, with descendants=true
+// also those in the notebooks nested below it. It takes the same paging and
+// tag filters as GetAllNotes.
+func (h *Handlers) GetNotebookNotes(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.GetNotebookNotes"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		notebookID, ok := idParam(w, r, log, "notebook_id")
+		if !ok {
+			return
+		}
+
+		filter, ok := noteFilter(w, r, log)
+		if !ok {
+			return
+		}
+		filter.NotebookID = notebookID
+		if v := r.URL.Query().Get("descendants"); v != "" {
+			descendants, err := strconv.ParseBool(v)
+			if err != nil {
+				response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "descendants must be true or false")
+				return
+			}
+			filter.Descendants = descendants
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		// an empty list would hide that the notebook does not exist
+		if _, err = h.notebookRepo.GetNotebook(r.Context(), userId, notebookID); err != nil {
+			log.InfoContext(r.Context(), "failed to get notebook", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		notes, err := h.noteRepo.GetAllNotes(r.Context(), userId, filter)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get notes", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "OK",
+			"userID":     userId,
+			"notebookID": notebookID,
+			"notes":      notes,
+		})
+	}
+}
+
+func (h *Handlers) RenameNotebook(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.RenameNotebook"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		notebookID, ok := idParam(w, r, log, "notebook_id")
+		if !ok {
+			return
+		}
+
+		var input models.RenameNotebookInput
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.notebookRepo.RenameNotebook(r.Context(), userId, notebookID, input.Name); err != nil {
+			log.InfoContext(r.Context(), "failed to rename notebook", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "notebook renamed", slog.Int("notebookId", notebookID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "OK",
+			"userID":     userId,
+			"notebookID": notebookID,
+		})
+	}
+}
+
+func (h *Handlers) MoveNotebook(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.MoveNotebook"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		notebookID, ok := idParam(w, r, log, "notebook_id")
+		if !ok {
+			return
+		}
+
+		var input models.MoveNotebookInput
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.notebookRepo.MoveNotebook(r.Context(), userId, notebookID, input.ParentID); err != nil {
+			log.InfoContext(r.Context(), "failed to move notebook", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "notebook moved", slog.Int("notebookId", notebookID), slog.Any("parentId", input.ParentID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "OK",
+			"userID":     userId,
+			"notebookID": notebookID,
+			"parentID":   input.ParentID,
+		})
+	}
+}
+
+func (h *Handlers) DeleteNotebook(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.DeleteNotebook"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		notebookID, ok := idParam(w, r, log, "notebook_id")
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.notebookRepo.DeleteNotebook(r.Context(), userId, notebookID); err != nil {
+			log.InfoContext(r.Context(), "failed to delete notebook", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "notebook deleted", slog.Int("notebookId", notebookID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "OK",
+			"userID":     userId,
+			"notebookID": notebookID,
+		})
+	}
+}
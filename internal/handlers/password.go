@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type changePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,maxbytes=72,password"`
+}
+
+// ChangePassword replaces the password, signs out every other session and
+// returns fresh tokens for the current one.
+func (h *Handlers) ChangePassword(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ChangePassword"
+
+		var input changePasswordInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		token, err := mw.GetAccessToken(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get access token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		err = h.userRepo.VerifyPassword(r.Context(), token.UserID, input.CurrentPassword)
+		if errors.Is(err, storage.ErrInvalidCredentials) {
+			log.InfoContext(r.Context(), "invalid current password", slog.Int("userId", token.UserID))
+			response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidPassword, "invalid current password")
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to verify password", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.userRepo.UpdatePassword(r.Context(), token.UserID, input.NewPassword); err != nil {
+			log.ErrorContext(r.Context(), "failed to update password", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.revokeSessions(r.Context(), token.UserID); err != nil {
+			log.ErrorContext(r.Context(), "failed to revoke sessions", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if err = h.revocations.Revoke(r.Context(), token); err != nil {
+			log.ErrorContext(r.Context(), "failed to revoke access token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "password changed", slog.Int("userId", token.UserID))
+		h.issueTokens(w, r, log, token.UserID, "")
+	}
+}
+
+type forgotPasswordInput struct {
+	Username string `json:"username" validate:"required"`
+}
+
+// ForgotPassword always answers 202, so it can't be used to find out which usernames exist.
+func (h *Handlers) ForgotPassword(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ForgotPassword"
+
+		var input forgotPasswordInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		accepted := map[string]interface{}{
+			"status": "OK",
+		}
+
+		user, err := h.userRepo.GetUser(r.Context(), input.Username)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.InfoContext(r.Context(), "password reset for unknown user", slog.String("username", input.Username))
+			response.RespondJSON(w, http.StatusAccepted, accepted)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		token, err := h.resetRepo.CreateResetToken(r.Context(), user.ID)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to create reset token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.notifier.NotifyPasswordReset(user, token); err != nil {
+			log.ErrorContext(r.Context(), "failed to deliver reset token", sl.Err(err))
+		}
+
+		log.InfoContext(r.Context(), "password reset requested", slog.Int("userId", user.ID))
+		response.RespondJSON(w, http.StatusAccepted, accepted)
+	}
+}
+
+type resetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,maxbytes=72,password"`
+}
+
+func (h *Handlers) ResetPassword(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ResetPassword"
+
+		var input resetPasswordInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, err := h.resetRepo.ConsumeResetToken(r.Context(), input.Token)
+		if errors.Is(err, storage.ErrInvalidResetToken) {
+			log.InfoContext(r.Context(), "invalid reset token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to consume reset token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.userRepo.UpdatePassword(r.Context(), userId, input.NewPassword); err != nil {
+			log.ErrorContext(r.Context(), "failed to update password", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.revokeSessions(r.Context(), userId); err != nil {
+			log.ErrorContext(r.Context(), "failed to revoke sessions", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "password reset", slog.Int("userId", userId))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBodyBytes caps every JSON request body, the note content limit included.
+const maxBodyBytes = 1 << 20
+
+var (
+	validate = newValidator()
+
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	// tag names end up in URL paths, so no dots that could pass for a format extension
+	tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// report fields the way clients send them
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("tag", func(fl validator.FieldLevel) bool {
+		return tagPattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		var letter, digit bool
+		for _, c := range fl.Field().String() {
+			letter = letter || unicode.IsLetter(c)
+			digit = digit || unicode.IsDigit(c)
+		}
+		return letter && digit
+	})
+	// bcrypt rejects passwords longer than 72 bytes, max would count runes
+	_ = v.RegisterValidation("maxbytes", func(fl validator.FieldLevel) bool {
+		limit, err := strconv.Atoi(fl.Param())
+		return err == nil && len(fl.Field().String()) <= limit
+	})
+
+	return v
+}
+
+// decodeJSON reads the request body into dst and checks it against its validate tags.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	if err := render.DecodeJSON(r.Body, dst); err != nil {
+		return err
+	}
+
+	return validate.Struct(dst)
+}
+
+// respondDecodeError answers a failed decodeJSON, listing the invalid fields when there are any.
+func respondDecodeError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		log.InfoContext(r.Context(), "invalid request", sl.Err(err))
+		response.RespondValidationError(w, r, validationErrs)
+		return
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		log.InfoContext(r.Context(), "request body too large", sl.Err(err))
+		response.RespondProblem(w, r, http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge, "request body too large")
+		return
+	}
+
+	log.ErrorContext(r.Context(), "failed to decode request body", sl.Err(err))
+	response.RespondProblem(w, r, http.StatusBadRequest, response.CodeInvalidBody, "invalid request body")
+}
+
+// idParam reads a numeric id from the URL parameter key. It answers the
+// request itself and reports false if the id is missing or malformed.
+func idParam(w http.ResponseWriter, r *http.Request, log *slog.Logger, key string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, key))
+	if err != nil || id < 1 {
+		log.InfoContext(r.Context(), "invalid id in url", slog.String("param", key), slog.String("value", chi.URLParam(r, key)))
+		response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "invalid "+strings.ReplaceAll(key, "_", " "))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// Paging defaults for list endpoints, larger limits are cut to maxPageLimit.
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
+// pageParams reads limit and offset from the query string. It answers the
+// request itself and reports false if either is not a valid number.
+func pageParams(w http.ResponseWriter, r *http.Request, log *slog.Logger) (limit, offset int, ok bool) {
+	q := r.URL.Query()
+	limit = defaultPageLimit
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 {
+			log.InfoContext(r.Context(), "invalid limit", slog.String("value", v))
+			response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "limit must be a positive number")
+			return 0, 0, false
+		}
+		limit = min(l, maxPageLimit)
+	}
+
+	if v := q.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			log.InfoContext(r.Context(), "invalid offset", slog.String("value", v))
+			response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "offset must not be negative")
+			return 0, 0, false
+		}
+		offset = o
+	}
+
+	return limit, offset, true
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/diff"
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetRevisions lists the past versions of a note, newest first.
+func (h *Handlers) GetRevisions(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.GetRevisions"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+
+		limit, offset, ok := pageParams(w, r, log)
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		revisions, err := h.revisionRepo.GetRevisions(r.Context(), userId, noteID, limit, offset)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to get revisions", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "revisions listed", slog.Int("noteId", noteID), slog.Int("revisions", len(revisions)))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    "OK",
+			"userID":    userId,
+			"noteID":    noteID,
+			"revisions": revisions,
+		})
+	}
+}
+
+func (h *Handlers) GetRevision(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.GetRevision"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+		revisionID, ok := idParam(w, r, log, "revision_id")
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		revision, err := h.revisionRepo.GetRevision(r.Context(), userId, noteID, revisionID)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to get revision", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "revision found", slog.Int("noteId", noteID), slog.Int("revisionId", revisionID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "OK",
+			"userID":   userId,
+			"revision": revision,
+		})
+	}
+}
+
+// DiffRevisions compares the revisions given by the from and to query
+// parameters. Without to, from is compared with the note as it is now.
+func (h *Handlers) DiffRevisions(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.DiffRevisions"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+
+		q := r.URL.Query()
+		from, err := strconv.Atoi(q.Get("from"))
+		if err != nil || from < 1 {
+			response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "from must be a revision id")
+			return
+		}
+		to := 0
+		if v := q.Get("to"); v != "" {
+			if to, err = strconv.Atoi(v); err != nil || to < 1 {
+				response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "to must be a revision id")
+				return
+			}
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		fromTitle, fromContent, err := h.noteVersion(r.Context(), userId, noteID, from)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to get revision", sl.Err(err), slog.Int("revisionId", from))
+			respondError(w, r, err)
+			return
+		}
+		toTitle, toContent, err := h.noteVersion(r.Context(), userId, noteID, to)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to get revision", sl.Err(err), slog.Int("revisionId", to))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "revisions compared", slog.Int("noteId", noteID), slog.Int("from", from), slog.Int("to", to))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"diff": models.NoteDiff{
+				From:      from,
+				To:        to,
+				TitleFrom: fromTitle,
+				TitleTo:   toTitle,
+				Diff:      diff.Unified(versionLabel(from), versionLabel(to), fromContent, toContent),
+			},
+		})
+	}
+}
+
+// RestoreRevision brings back the title and content of a revision. It is an
+// update like any other, so the version it replaces becomes a revision too.
+func (h *Handlers) RestoreRevision(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.RestoreRevision"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+		revisionID, ok := idParam(w, r, log, "revision_id")
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		revision, err := h.revisionRepo.GetRevision(r.Context(), userId, noteID, revisionID)
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to get revision", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		input := models.UpdateNoteInput{Title: &revision.Title, Content: &revision.Content}
+		if err = h.noteRepo.UpdateNote(r.Context(), userId, noteID, input); err != nil {
+			log.ErrorContext(r.Context(), "failed to restore revision", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "revision restored", slog.Int("noteId", noteID), slog.Int("revisionId", revisionID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "OK",
+			"userID":     userId,
+			"noteID":     noteID,
+			"revisionID": revisionID,
+		})
+	}
+}
+
+// noteVersion returns the title and content of a revision, or of the note as
+// it is now for revision zero.
+func (h *Handlers) noteVersion(ctx context.Context, userId, noteId, revisionId int) (string, string, error) {
+	if revisionId == 0 {
+		note, err := h.noteRepo.GetNote(ctx, userId, noteId)
+		return note.Title, note.Content, err
+	}
+
+	revision, err := h.revisionRepo.GetRevision(ctx, userId, noteId, revisionId)
+	return revision.Title, revision.Content, err
+}
+
+func versionLabel(revisionId int) string {
+	if revisionId == 0 {
+		return "current"
+	}
+	return fmt.Sprintf("revision %d", revisionId)
+}
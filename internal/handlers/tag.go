@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func (h *Handlers) ListTags(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ListTags"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		tags, err := h.tagRepo.ListTags(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list tags", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"tags":   tags,
+		})
+	}
+}
+
+func (h *Handlers) RenameTag(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.RenameTag"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		name, ok := tagParam(w, r, log)
+		if !ok {
+			return
+		}
+
+		var input models.RenameTagInput
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+		newName := strings.ToLower(input.Name)
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.tagRepo.RenameTag(r.Context(), userId, name, newName); err != nil {
+			log.InfoContext(r.Context(), "failed to rename tag", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "tag renamed", slog.String("tag", name), slog.String("name", newName))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"tag":    newName,
+		})
+	}
+}
+
+func (h *Handlers) MergeTag(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.MergeTag"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		name, ok := tagParam(w, r, log)
+		if !ok {
+			return
+		}
+
+		var input models.MergeTagInput
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+		into := strings.ToLower(input.Into)
+		if into == name {
+			response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "cannot merge a tag into itself")
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.tagRepo.MergeTag(r.Context(), userId, name, into); err != nil {
+			log.InfoContext(r.Context(), "failed to merge tag", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "tag merged", slog.String("tag", name), slog.String("into", into))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"tag":    into,
+		})
+	}
+}
+
+func (h *Handlers) DeleteTag(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.DeleteTag"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		name, ok := tagParam(w, r, log)
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.tagRepo.DeleteTag(r.Context(), userId, name); err != nil {
+			log.InfoContext(r.Context(), "failed to delete tag", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "tag deleted", slog.String("tag", name))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"tag":    name,
+		})
+	}
+}
+
+// tagParam reads the {tag} URL parameter normalized like stored tag names. It
+// answers the request itself and reports false if the name is invalid.
+func tagParam(w http.ResponseWriter, r *http.Request, log *slog.Logger) (string, bool) {
+	name, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err == nil {
+		name = strings.ToLower(name)
+		err = validate.Var(name, "required,max=50,tag")
+	}
+	if err != nil {
+		log.InfoContext(r.Context(), "invalid tag name", sl.Err(err))
+		response.RespondProblem(w, r, http.StatusBadRequest, response.CodeBadRequest, "invalid tag name")
+		return "", false
+	}
+
+	return name, true
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetTrash lists deleted notes, most recently deleted first.
+func (h *Handlers) GetTrash(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.GetTrash"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		limit, offset, ok := pageParams(w, r, log)
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		notes, err := h.noteRepo.GetTrash(r.Context(), userId, limit, offset)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get trash", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "trash listed", slog.Int("notes", len(notes)))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"notes":  notes,
+		})
+	}
+}
+
+func (h *Handlers) RestoreNote(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.RestoreNote"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.noteRepo.RestoreNote(r.Context(), userId, noteID); err != nil {
+			log.InfoContext(r.Context(), "failed to restore note", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "note restored", slog.Int("noteId", noteID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"noteID": noteID,
+		})
+	}
+}
+
+// PurgeNote deletes a note from the trash for good. Notes outside the trash
+// are not found, they have to be deleted first.
+func (h *Handlers) PurgeNote(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.PurgeNote"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		noteID, ok := idParam(w, r, log, "note_id")
+		if !ok {
+			return
+		}
+
+		userId, err := mw.GetUserID(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get user id", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.noteRepo.PurgeNote(r.Context(), userId, noteID); err != nil {
+			log.InfoContext(r.Context(), "failed to purge note", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		log.InfoContext(r.Context(), "note purged", slog.Int("noteId", noteID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+			"userID": userId,
+			"noteID": noteID,
+		})
+	}
+}
@@ -3,16 +3,22 @@ package handlers
 import (
 	"context"
 	"errors"
+	"github/yusupovkuzs/GoNotesApp/internal/auth"
+	mw "github/yusupovkuzs/GoNotesApp/internal/middleware"
 	"github/yusupovkuzs/GoNotesApp/internal/models"
 	"github/yusupovkuzs/GoNotesApp/internal/storage"
 	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
 	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"io"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
 )
 
 type Response struct {
@@ -27,37 +33,51 @@ func (h *Handlers) UserIdentity(log *slog.Logger) func(http.Handler) http.Handle
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			const op = "handlers.UserIdentity"
 
-			log = log.With(
+			log := log.With(
 				slog.String("op", op),
 				slog.String("request_id", middleware.GetReqID(r.Context())),
 			)
 
 			header := r.Header.Get(authorizationHeader)
 			if header == "" {
-				log.Error("empty authorization header")
-				render.JSON(w, r, response.Error("empty authorization header"))
-				response.RespondError(w, http.StatusUnauthorized, "empty authorization header")
+				log.ErrorContext(r.Context(), "empty authorization header")
+				h.metrics.AuthFailure(response.CodeUnauthorized)
+				response.RespondProblem(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "empty authorization header")
 				return
 			}
 
 			parts := strings.Split(header, " ")
 			if len(parts) != 2 {
-				log.Error("invalid authorization header")
-				render.JSON(w, r, response.Error("invalid authorization header"))
-				response.RespondError(w, http.StatusUnauthorized, "invalid authorization header")
+				log.ErrorContext(r.Context(), "invalid authorization header")
+				h.metrics.AuthFailure(response.CodeUnauthorized)
+				response.RespondProblem(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "invalid authorization header")
 				return
 			}
 
-			userId, err := h.userRepo.ParseToken(parts[1])
+			token, err := h.verifier.VerifyAccessToken(parts[1])
 			if err != nil {
-				log.Error("invalid token", sl.Err(err))
-				render.JSON(w, r, response.Error("invalid token"))
-				response.RespondError(w, http.StatusUnauthorized, err.Error())
+				log.ErrorContext(r.Context(), "invalid token", sl.Err(err))
+				h.metrics.AuthFailure(codeInvalidToken)
+				response.RespondProblem(w, r, http.StatusUnauthorized, codeInvalidToken, "invalid token")
 				return
 			}
 
-			log.Info("user identity found", slog.Int("userId", userId))
-			ctx := context.WithValue(r.Context(), "userId", userId)
+			revoked, err := h.revocations.IsRevoked(r.Context(), token)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to check token revocation", sl.Err(err))
+				respondError(w, r, err)
+				return
+			}
+			if revoked {
+				log.InfoContext(r.Context(), "token has been revoked", slog.Int("userId", token.UserID))
+				h.metrics.AuthFailure(codeTokenRevoked)
+				response.RespondProblem(w, r, http.StatusUnauthorized, codeTokenRevoked, "token has been revoked")
+				return
+			}
+
+			log.InfoContext(r.Context(), "user identity found", slog.Int("userId", token.UserID))
+			ctx := context.WithValue(r.Context(), "userId", token.UserID)
+			ctx = context.WithValue(ctx, "accessToken", token)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -69,38 +89,50 @@ func (h *Handlers) Register(log *slog.Logger) http.HandlerFunc {
 
 		var input models.User
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		err := render.DecodeJSON(r.Body, &input)
-		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
 			return
 		}
-		log.Info("request body decoded successfully", slog.Any("request", input))
+		log.InfoContext(r.Context(), "request body decoded successfully", slog.String("username", input.Username))
 
-		if input.Username == "" || input.Password == "" {
-			log.Error("invalid username or password")
-			response.RespondError(w, http.StatusBadRequest, "invalid username or password")
+		if input.Email == "" && h.requireVerifiedEmail {
+			log.InfoContext(r.Context(), "email is required")
+			response.RespondProblem(w, r, http.StatusUnprocessableEntity, codeEmailRequired, "email is required")
 			return
 		}
 
-		id, err := h.userRepo.CreateUser(input)
+		id, err := h.userRepo.CreateUser(r.Context(), input)
 		if errors.Is(err, storage.ErrUsernameTaken) {
-			log.Error("username is already taken", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, "username is already taken")
+			log.ErrorContext(r.Context(), "username is already taken", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if errors.Is(err, storage.ErrEmailTaken) {
+			log.ErrorContext(r.Context(), "email is already taken", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 		if err != nil {
-			log.Error("failed to create user", sl.Err(err))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to create user", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("user created successfully", slog.Int("id", id))
+		if input.Email != "" {
+			input.ID = id
+			// the user can ask for another link if this one gets lost
+			if err = h.sendEmailVerification(input); err != nil {
+				log.ErrorContext(r.Context(), "failed to send email verification", sl.Err(err))
+			}
+		}
+
+		log.InfoContext(r.Context(), "user created successfully", slog.Int("id", id))
+		h.metrics.UserRegistered()
 		response.RespondJSON(w, http.StatusCreated, map[string]interface{}{
 			"status": "OK",
 			"id":     id,
@@ -109,8 +141,8 @@ func (h *Handlers) Register(log *slog.Logger) http.HandlerFunc {
 }
 
 type signInInput struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 func (h *Handlers) Login(log *slog.Logger) http.HandlerFunc {
@@ -119,30 +151,261 @@ func (h *Handlers) Login(log *slog.Logger) http.HandlerFunc {
 
 		var input signInInput
 
-		log = log.With(
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+		log.InfoContext(r.Context(), "request body decoded successfully", slog.String("username", input.Username))
+
+		ip := clientIP(r)
+		attempt, wait := h.throttle.Begin(input.Username, ip)
+		if attempt == nil {
+			log.InfoContext(r.Context(), "login throttled", slog.String("username", input.Username), slog.String("ip", ip))
+			h.metrics.AuthFailure(codeLoginThrottled)
+			respondThrottled(w, r, wait)
+			return
+		}
+		// paths that neither pass nor fail the check give the attempt back
+		defer attempt.Release()
+
+		user, err := h.userRepo.Authenticate(r.Context(), input.Username, input.Password)
+		if errors.Is(err, storage.ErrInvalidCredentials) {
+			log.InfoContext(r.Context(), "invalid credentials", slog.String("username", input.Username))
+			h.loginFailed(r, attempt, input.Username, ip, codeInvalidCredentials)
+			respondError(w, r, err)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to authenticate user", sl.Err(err))
+			response.RespondInternalError(w, r)
+			return
+		}
+
+		if h.requireVerifiedEmail && !user.EmailVerified {
+			log.InfoContext(r.Context(), "email is not verified", slog.Int("userId", user.ID))
+			response.RespondProblem(w, r, http.StatusForbidden, codeEmailNotVerified, "email is not verified")
+			return
+		}
+
+		if user.TOTPEnabled {
+			mfaToken, err := h.tokens.IssueMFAChallenge(user.ID)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to create mfa challenge", sl.Err(err))
+				respondError(w, r, err)
+				return
+			}
+
+			// the account's failures are only forgotten once the second step succeeds
+			log.InfoContext(r.Context(), "mfa challenge issued", slog.Int("userId", user.ID))
+			response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+				"status":       "OK",
+				"mfa_required": true,
+				"mfa_token":    mfaToken,
+			})
+			return
+		}
+
+		attempt.Success()
+		h.issueTokens(w, r, log, user.ID, "")
+	}
+}
+
+// loginFailed records a failed login attempt and audits the lockout it may cause.
+func (h *Handlers) loginFailed(r *http.Request, attempt *auth.Attempt, account, ip, reason string) {
+	h.metrics.AuthFailure(reason)
+	if attempt.Failure() {
+		h.metrics.AuthFailure(reasonAccountLocked)
+		h.audit.WarnContext(r.Context(), "account locked out after repeated login failures",
+			slog.String("event", "account_lockout"),
+			slog.String("username", account),
+			slog.String("ip", ip),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+	}
+}
+
+func respondThrottled(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	response.RespondProblem(w, r, http.StatusTooManyRequests, codeLoginThrottled, "too many failed attempts, try again later")
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+type refreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (h *Handlers) Refresh(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.Refresh"
+
+		var input refreshInput
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := decodeJSON(w, r, &input); err != nil {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		userId, refreshToken, err := h.refreshRepo.RotateRefreshToken(r.Context(), input.RefreshToken)
+		if errors.Is(err, storage.ErrRefreshTokenReused) {
+			log.WarnContext(r.Context(), "refresh token reuse detected, token family revoked", sl.Err(err))
+			h.metrics.AuthFailure(codeRefreshTokenReused)
+			respondError(w, r, err)
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidRefreshToken) {
+			log.InfoContext(r.Context(), "invalid refresh token", sl.Err(err))
+			h.metrics.AuthFailure(codeInvalidRefreshToken)
+			respondError(w, r, err)
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to rotate refresh token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		h.issueTokens(w, r, log, userId, refreshToken)
+	}
+}
+
+type logoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout revokes the presented access token and, if given, the refresh token family.
+func (h *Handlers) Logout(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.Logout"
+
+		var input logoutInput
+
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		err := render.DecodeJSON(r.Body, &input)
+		// the body is optional
+		if err := decodeJSON(w, r, &input); err != nil && !errors.Is(err, io.EOF) {
+			respondDecodeError(w, r, log, err)
+			return
+		}
+
+		token, err := mw.GetAccessToken(r)
 		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+			log.ErrorContext(r.Context(), "failed to get access token", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
-		log.Info("request body decoded successfully", slog.Any("request", input))
 
-		token, err := h.userRepo.GenerateToken(input.Username, input.Password)
+		if err = h.revocations.Revoke(r.Context(), token); err != nil {
+			log.ErrorContext(r.Context(), "failed to revoke access token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if input.RefreshToken != "" {
+			err = h.refreshRepo.RevokeRefreshToken(r.Context(), token.UserID, input.RefreshToken)
+			if err != nil && !errors.Is(err, storage.ErrInvalidRefreshToken) {
+				log.ErrorContext(r.Context(), "failed to revoke refresh token", sl.Err(err))
+				respondError(w, r, err)
+				return
+			}
+		}
+
+		log.InfoContext(r.Context(), "user logged out", slog.Int("userId", token.UserID))
+		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "OK",
+		})
+	}
+}
+
+// LogoutAll revokes every access and refresh token issued to the user so far.
+func (h *Handlers) LogoutAll(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.LogoutAll"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		token, err := mw.GetAccessToken(r)
 		if err != nil {
-			log.Error("failed to create token", sl.Err(err))
-			response.RespondError(w, http.StatusInternalServerError, err.Error())
+			log.ErrorContext(r.Context(), "failed to get access token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+
+		if err = h.revokeSessions(r.Context(), token.UserID); err != nil {
+			log.ErrorContext(r.Context(), "failed to revoke sessions", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+		if err = h.revocations.Revoke(r.Context(), token); err != nil {
+			log.ErrorContext(r.Context(), "failed to revoke access token", sl.Err(err))
+			respondError(w, r, err)
 			return
 		}
 
-		log.Info("token generated", slog.String("token", token))
+		log.InfoContext(r.Context(), "user logged out everywhere", slog.Int("userId", token.UserID))
 		response.RespondJSON(w, http.StatusOK, map[string]interface{}{
 			"status": "OK",
-			"token":  token,
 		})
 	}
 }
+
+// revokeSessions revokes all of the user's tokens. The watermark has a one
+// second resolution, so a token presented by the caller should be revoked by jti as well.
+func (h *Handlers) revokeSessions(ctx context.Context, userId int) error {
+	if err := h.revocations.RevokeAll(ctx, userId); err != nil {
+		return err
+	}
+
+	return h.refreshRepo.RevokeUserRefreshTokens(ctx, userId)
+}
+
+// issueTokens responds with a fresh access token; a new refresh token family
+// is started unless refreshToken already holds a rotated one.
+func (h *Handlers) issueTokens(w http.ResponseWriter, r *http.Request, log *slog.Logger, userId int, refreshToken string) {
+	token, err := h.tokens.IssueAccessToken(userId)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to create token", sl.Err(err))
+		respondError(w, r, err)
+		return
+	}
+
+	if refreshToken == "" {
+		refreshToken, err = h.refreshRepo.CreateRefreshToken(r.Context(), userId)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to create refresh token", sl.Err(err))
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	log.InfoContext(r.Context(), "tokens generated", slog.Int("userId", userId))
+	response.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":        "OK",
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(h.tokens.AccessTokenTTL().Seconds()),
+	})
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/internal/lifecycle"
+	"github/yusupovkuzs/GoNotesApp/pkg/response"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusNotReady is reported while the instance starts up or shuts down.
+	StatusNotReady = "not_ready"
+)
+
+// CheckFunc reports whether a dependency is usable. It must return once ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status    string            `json:"status"`
+	Checks    map[string]Result `json:"checks,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Registry runs the registered checks for readiness probes. Results are cached
+// for a while so frequent probes don't turn into load on the dependencies.
+type Registry struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu     sync.Mutex
+	checks []check
+	last   Report
+}
+
+func NewRegistry(cfg config.HealthConfig) *Registry {
+	return &Registry{timeout: cfg.CheckTimeout, cacheTTL: cfg.CacheTTL}
+}
+
+func (r *Registry) Register(name string, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, check{name: name, fn: fn})
+	r.last = Report{}
+}
+
+// Run runs all checks concurrently, each bounded by the check timeout. Probes
+// arriving while a run is in progress wait for it and share its result, so
+// the checks don't stop when the probe that started them goes away.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+
+	if !r.last.CheckedAt.IsZero() && time.Since(r.last.CheckedAt) < r.cacheTTL {
+		return r.last
+	}
+
+	results := make([]Result, len(r.checks))
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c.fn)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    StatusOK,
+		Checks:    make(map[string]Result, len(r.checks)),
+		CheckedAt: time.Now(),
+	}
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	r.last = report
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, fn CheckFunc) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	if err == nil {
+		// a check that ignores ctx must not pass once it ran out of time
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusOK, Duration: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// Liveness only tells that the process serves requests, it never looks at
+// dependencies, so a database outage doesn't get the instance restarted.
+func Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.RespondJSON(w, http.StatusOK, Report{Status: StatusOK, CheckedAt: time.Now()})
+	}
+}
+
+// Readiness answers 503 while the instance is not ready or any check fails.
+func (r *Registry) Readiness(ready *lifecycle.Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !ready.Ready() {
+			response.RespondJSON(w, http.StatusServiceUnavailable, Report{Status: StatusNotReady, CheckedAt: time.Now()})
+			return
+		}
+
+		report := r.Run(req.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		response.RespondJSON(w, status, report)
+	}
+}
+
+// PingCheck checks that the database accepts connections.
+func PingCheck(db *sql.DB) CheckFunc {
+	return db.PingContext
+}
+
+// DialCheck checks that a TCP connection to addr can be opened.
+func DialCheck(addr string) CheckFunc {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
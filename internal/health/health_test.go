@@ -0,0 +1,36 @@
+package health
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"testing"
+	"time"
+)
+
+func TestRunOutlivesProbe(t *testing.T) {
+	r := NewRegistry(config.HealthConfig{CheckTimeout: time.Second, CacheTTL: time.Minute})
+	r.Register("db", func(ctx context.Context) error {
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := r.Run(ctx); report.Status != StatusOK {
+		t.Fatalf("a cancelled probe failed the checks: %+v", report)
+	}
+	if report := r.Run(context.Background()); report.Status != StatusOK {
+		t.Fatalf("cached report: %+v", report)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := NewRegistry(config.HealthConfig{CheckTimeout: 10 * time.Millisecond, CacheTTL: time.Minute})
+	r.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if report := r.Run(context.Background()); report.Status != StatusFail {
+		t.Fatalf("a check past its timeout passed: %+v", report)
+	}
+}
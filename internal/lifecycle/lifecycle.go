@@ -0,0 +1,83 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Readiness tells load balancers whether the instance should receive traffic.
+// It starts out not ready.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	r.ready.Store(ready)
+}
+
+func (r *Readiness) Ready() bool {
+	return r.ready.Load()
+}
+
+type worker struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Workers runs background jobs and stops them in the reverse order they were
+// started, so a job can rely on the ones started before it.
+type Workers struct {
+	log *slog.Logger
+
+	mu      sync.Mutex
+	workers []worker
+}
+
+func NewWorkers(log *slog.Logger) *Workers {
+	return &Workers{log: log}
+}
+
+// Go starts run in its own goroutine. run must return once ctx is cancelled.
+func (w *Workers) Go(name string, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wk := worker{name: name, cancel: cancel, done: make(chan struct{})}
+
+	w.mu.Lock()
+	w.workers = append(w.workers, wk)
+	w.mu.Unlock()
+
+	go func() {
+		defer close(wk.done)
+		run(ctx)
+	}()
+	w.log.Debug("worker started", slog.String("worker", name))
+}
+
+// Stop cancels the workers one by one and waits for each to return. It gives up
+// waiting once ctx is done.
+func (w *Workers) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	workers := w.workers
+	w.workers = nil
+	w.mu.Unlock()
+
+	for i := len(workers) - 1; i >= 0; i-- {
+		wk := workers[i]
+		wk.cancel()
+
+		select {
+		case <-wk.done:
+			w.log.Debug("worker stopped", slog.String("worker", wk.name))
+		case <-ctx.Done():
+			for _, rest := range workers[:i] {
+				rest.cancel()
+			}
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "notes"
+
+// Metrics owns the Prometheus registry and every collector the app exports.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	authFailures    *prometheus.CounterVec
+	notesCreated    prometheus.Counter
+	usersRegistered prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern, method and status class.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and status class.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_failures_total",
+			Help:      "Rejected authentication attempts by reason.",
+		}, []string{"reason"}),
+		notesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notes_created_total",
+			Help:      "Notes created by this process since it started, see stored_notes for the total.",
+		}),
+		usersRegistered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "users_registered_total",
+			Help:      "Users registered with this process since it started, see registered_users for the total.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.authFailures,
+		m.notesCreated,
+		m.usersRegistered,
+	)
+
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterTotals exports the number of stored notes and users, counted in db
+// on every scrape with the given timeout.
+func (m *Metrics) RegisterTotals(db *sql.DB, timeout time.Duration) {
+	m.registry.MustRegister(&totalsCollector{
+		db:      db,
+		timeout: timeout,
+		notes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "stored_notes"),
+			"Notes currently stored, the trash excluded.", nil, nil,
+		),
+		users: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "registered_users"),
+			"Users currently registered.", nil, nil,
+		),
+	})
+}
+
+// totalsCollector reads the business gauges from the database, process
+// counters would start over at every restart and differ between instances.
+type totalsCollector struct {
+	db      *sql.DB
+	timeout time.Duration
+	notes   *prometheus.Desc
+	users   *prometheus.Desc
+}
+
+func (c *totalsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.notes
+	ch <- c.users
+}
+
+func (c *totalsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	c.collect(ctx, ch, c.notes, fmt.Sprintf("SELECT count(*) FROM %s WHERE deleted_at IS NULL", storage.NotesTable))
+	c.collect(ctx, ch, c.users, fmt.Sprintf("SELECT count(*) FROM %s", storage.UsersTable))
+}
+
+func (c *totalsCollector) collect(ctx context.Context, ch chan<- prometheus.Metric, desc *prometheus.Desc, query string) {
+	var n int64
+	if err := c.db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records every request under its chi route pattern rather than the
+// raw path, which would create a series per note id.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"method": r.Method,
+			"route":  route,
+			"status": strconv.Itoa(status/100) + "xx",
+		}
+
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (m *Metrics) AuthFailure(reason string) {
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) NoteCreated() {
+	m.notesCreated.Inc()
+}
+
+func (m *Metrics) UserRegistered() {
+	m.usersRegistered.Inc()
+}
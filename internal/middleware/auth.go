@@ -2,11 +2,13 @@ package middleware
 
 import (
 	"errors"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
 	"net/http"
 )
 
 const (
-	userCtx = "userId"
+	userCtx  = "userId"
+	tokenCtx = "accessToken"
 )
 
 func GetUserID(r *http.Request) (int, error) {
@@ -22,3 +24,12 @@ func GetUserID(r *http.Request) (int, error) {
 
 	return idInt, nil
 }
+
+func GetAccessToken(r *http.Request) (models.AccessToken, error) {
+	token, ok := r.Context().Value(tokenCtx).(models.AccessToken)
+	if !ok {
+		return models.AccessToken{}, errors.New("access token is not found")
+	}
+
+	return token, nil
+}
@@ -1,27 +1,79 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
 type Note struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"userId"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID      int      `json:"id"`
+	UserID  int      `json:"userId"`
+	Title   string   `json:"title" validate:"required,max=200"`
+	Content string   `json:"content" validate:"max=100000"`
+	Tags    []string `json:"tags" validate:"max=20,dive,min=1,max=50,tag"`
+	// NotebookID is nil for notes outside any notebook.
+	NotebookID *int      `json:"notebook_id" validate:"omitempty,min=1"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type NoteDTO struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Tags       []string  `json:"tags"`
+	NotebookID *int      `json:"notebook_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// DeletedAt is only set on notes listed from the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// NoteSearchResult is a note matching a search query. Snippet is an HTML
+// excerpt of the content, escaped, with the matches wrapped in <mark>.
+type NoteSearchResult struct {
+	NoteDTO
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
 
 type UpdateNoteInput struct {
-	Title   *string `json:"title"`
-	Content *string `json:"content"`
+	Title   *string `json:"title" validate:"omitempty,min=1,max=200"`
+	Content *string `json:"content" validate:"omitempty,max=100000"`
+	// Tags replaces the note's tags when set, an empty list removes them all.
+	Tags *[]string `json:"tags" validate:"omitempty,max=20,dive,min=1,max=50,tag"`
+}
+
+// MoveNoteInput files a note into a notebook, a null notebook_id takes it out.
+type MoveNoteInput struct {
+	NotebookID *int `json:"notebook_id" validate:"omitempty,min=1"`
+}
+
+type Tag struct {
+	Name      string `json:"name"`
+	NoteCount int    `json:"note_count"`
+}
+
+type RenameTagInput struct {
+	Name string `json:"name" validate:"required,max=50,tag"`
+}
+
+type MergeTagInput struct {
+	Into string `json:"into" validate:"required,max=50,tag"`
+}
+
+// NormalizeTags lowercases tag names and drops duplicates, keeping the first
+// occurrence's position. Tags are case-insensitive and stored normalized.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(t)
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+type Notebook struct {
+	ID     int `json:"id"`
+	UserID int `json:"userId"`
+	// ParentID is nil for top level notebooks.
+	ParentID  *int      `json:"parent_id" validate:"omitempty,min=1"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RenameNotebookInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// MoveNotebookInput re-parents a notebook, a null parent_id makes it top level.
+type MoveNotebookInput struct {
+	ParentID *int `json:"parent_id" validate:"omitempty,min=1"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// NoteRevision is a note's title and content as they were before AuthorID
+// updated it at CreatedAt.
+type NoteRevision struct {
+	ID        int       `json:"id"`
+	NoteID    int       `json:"note_id"`
+	AuthorID  int       `json:"author_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NoteDiff compares two versions of a note. A zero revision id stands for the
+// note as it is now, Diff is a unified diff of the content.
+type NoteDiff struct {
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	TitleFrom string `json:"title_from"`
+	TitleTo   string `json:"title_to"`
+	Diff      string `json:"diff"`
+}
@@ -4,12 +4,30 @@ import "time"
 
 type User struct {
 	ID        int       `json:"-"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
+	Username  string    `json:"username" validate:"required,min=3,max=32,username"`
+	Password  string    `json:"password" validate:"required,min=8,maxbytes=72,password"`
+	Email     string    `json:"email,omitempty" validate:"omitempty,email,max=254"`
 	CreatedAt time.Time `json:"created_at"`
+
+	PasswordHash  string `json:"-"`
+	TOTPEnabled   bool   `json:"-"`
+	EmailVerified bool   `json:"-"`
 }
 
 type CreateUserRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
+
+type AccessToken struct {
+	ID        string
+	UserID    int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+type TOTP struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
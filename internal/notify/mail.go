@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/pkg/mailer"
+)
+
+var ErrNoEmail = errors.New("user has no email address")
+
+// MailNotifier emails notifications to the user's address.
+type MailNotifier struct {
+	mailer mailer.Mailer
+}
+
+func NewMailNotifier(m mailer.Mailer) *MailNotifier {
+	return &MailNotifier{mailer: m}
+}
+
+func (n *MailNotifier) NotifyPasswordReset(user models.User, token string) error {
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+
+	return n.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nsomeone requested a password reset for your account.\n"+
+				"Use this token to choose a new password: %s\n\n"+
+				"If it wasn't you, you can ignore this email.\n",
+			user.Username, token,
+		),
+	})
+}
+
+func (n *MailNotifier) NotifyEmailVerification(user models.User, link string) error {
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+
+	return n.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nplease confirm your email address by opening this link:\n%s\n",
+			user.Username, link,
+		),
+	})
+}
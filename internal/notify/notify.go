@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/pkg/mailer"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverMail = "mail"
+)
+
+// Notifier delivers security related messages to users.
+type Notifier interface {
+	NotifyPasswordReset(user models.User, token string) error
+	NotifyEmailVerification(user models.User, link string) error
+}
+
+func New(cfg config.NotifierConfig, log *slog.Logger, m mailer.Mailer) (Notifier, error) {
+	switch cfg.Driver {
+	case DriverLog:
+		return NewLogNotifier(log), nil
+	case DriverFile:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("notifier file_path is required for the %q driver", DriverFile)
+		}
+		return NewFileNotifier(cfg.FilePath), nil
+	case DriverMail:
+		return NewMailNotifier(m), nil
+	default:
+		return nil, fmt.Errorf("unsupported notifier driver %q", cfg.Driver)
+	}
+}
+
+// LogNotifier writes notifications, secrets included, to the log. For local development only.
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log.With(slog.String("component", "notify/log"))}
+}
+
+func (n *LogNotifier) NotifyPasswordReset(user models.User, token string) error {
+	n.log.Info("password reset requested",
+		slog.String("username", user.Username),
+		slog.String("token", token),
+	)
+	return nil
+}
+
+func (n *LogNotifier) NotifyEmailVerification(user models.User, link string) error {
+	n.log.Info("email verification requested",
+		slog.String("username", user.Username),
+		slog.String("email", user.Email),
+		slog.String("link", link),
+	)
+	return nil
+}
+
+// FileNotifier appends notifications to a file, handy for local development and tests.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) NotifyPasswordReset(user models.User, token string) error {
+	return n.write(fmt.Sprintf("password reset requested for %s, token: %s", user.Username, token))
+}
+
+func (n *FileNotifier) NotifyEmailVerification(user models.User, link string) error {
+	return n.write(fmt.Sprintf("email verification for %s <%s>, link: %s", user.Username, user.Email, link))
+}
+
+func (n *FileNotifier) write(msg string) error {
+	const op = "notify.FileNotifier.write"
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	if _, err = fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
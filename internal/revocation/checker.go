@@ -0,0 +1,145 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	RevokeToken(ctx context.Context, jti string, userId int, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeAllTokens(ctx context.Context, userId int) (time.Time, error)
+	TokensRevokedBefore(ctx context.Context, userId int) (time.Time, error)
+}
+
+type tokenEntry struct {
+	revoked  bool
+	cachedAt time.Time
+	expires  time.Time
+}
+
+type userEntry struct {
+	revokedBefore time.Time
+	cachedAt      time.Time
+}
+
+// Checker answers revocation lookups from memory and falls back to the store
+// once a cached answer is older than ttl.
+type Checker struct {
+	store Store
+	ttl   time.Duration
+
+	mu        sync.RWMutex
+	tokens    map[string]tokenEntry
+	users     map[int]userEntry
+	lastPrune time.Time
+}
+
+func NewChecker(store Store, ttl time.Duration) *Checker {
+	return &Checker{
+		store:     store,
+		ttl:       ttl,
+		tokens:    make(map[string]tokenEntry),
+		users:     make(map[int]userEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+func (c *Checker) IsRevoked(ctx context.Context, token models.AccessToken) (bool, error) {
+	const op = "revocation.IsRevoked"
+
+	revokedBefore, err := c.revokedBefore(ctx, token.UserID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if token.IssuedAt.Before(revokedBefore) {
+		return true, nil
+	}
+
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.tokens[token.ID]
+	c.mu.RUnlock()
+	// a revoked token can never become valid again, so that answer never goes stale
+	if ok && (entry.revoked || now.Sub(entry.cachedAt) < c.ttl) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.store.IsTokenRevoked(ctx, token.ID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	c.setToken(token, revoked)
+	return revoked, nil
+}
+
+func (c *Checker) Revoke(ctx context.Context, token models.AccessToken) error {
+	if err := c.store.RevokeToken(ctx, token.ID, token.UserID, token.ExpiresAt); err != nil {
+		return err
+	}
+
+	c.setToken(token, true)
+	return nil
+}
+
+func (c *Checker) RevokeAll(ctx context.Context, userId int) error {
+	before, err := c.store.RevokeAllTokens(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.users[userId] = userEntry{revokedBefore: before, cachedAt: time.Now()}
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Checker) revokedBefore(ctx context.Context, userId int) (time.Time, error) {
+	c.mu.RLock()
+	entry, ok := c.users[userId]
+	c.mu.RUnlock()
+	if ok && time.Since(entry.cachedAt) < c.ttl {
+		return entry.revokedBefore, nil
+	}
+
+	before, err := c.store.TokensRevokedBefore(ctx, userId)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	c.mu.Lock()
+	c.users[userId] = userEntry{revokedBefore: before, cachedAt: time.Now()}
+	c.mu.Unlock()
+
+	return before, nil
+}
+
+func (c *Checker) setToken(token models.AccessToken, revoked bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens[token.ID] = tokenEntry{revoked: revoked, cachedAt: now, expires: token.ExpiresAt}
+
+	if now.Sub(c.lastPrune) < c.ttl {
+		return
+	}
+	c.lastPrune = now
+	for jti, e := range c.tokens {
+		if now.After(e.expires) || (!e.revoked && now.Sub(e.cachedAt) >= c.ttl) {
+			delete(c.tokens, jti)
+		}
+	}
+	for id, e := range c.users {
+		if now.Sub(e.cachedAt) >= c.ttl {
+			delete(c.users, id)
+		}
+	}
+}
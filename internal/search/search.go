@@ -0,0 +1,260 @@
+// Package search parses full-text search queries and provides the matching
+// used by storage backends without a full-text engine of their own.
+package search
+
+import (
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Highlighting shared by all backends. Snippets are HTML, the note content in
+// them is escaped so only the markers are markup.
+const (
+	StartSel = "<mark>"
+	StopSel  = "</mark>"
+	// MaxWords bounds the words in a snippet.
+	MaxWords = 35
+)
+
+// ts_headline cannot escape what it cuts out, so Postgres highlights with
+// these private use characters instead, after removing them from the content.
+// FromHeadline escapes its snippets and swaps them for StartSel and StopSel.
+const (
+	HeadlineStartSel = "\ue000"
+	HeadlineStopSel  = "\ue001"
+)
+
+var headlineMarkers = strings.NewReplacer(HeadlineStartSel, StartSel, HeadlineStopSel, StopSel)
+
+// FromHeadline turns a snippet highlighted with HeadlineStartSel and
+// HeadlineStopSel into one like those Match builds.
+func FromHeadline(s string) string {
+	return headlineMarkers.Replace(html.EscapeString(s))
+}
+
+// Weights of a hit in the title and in the content, the defaults of ts_rank
+// for the A and B labels Postgres gives them.
+const (
+	titleWeight   = 1.0
+	contentWeight = 0.4
+)
+
+// Term is a single word or a quoted phrase whose words have to follow each
+// other. With Prefix the last word also matches longer words starting with it.
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+// Query matches documents containing every one of its terms.
+type Query struct {
+	Terms []Term
+}
+
+// Parse reads a query such as `go "error handling" contex*`. Words are
+// lowercased runs of letters and digits, anything else separates them, so
+// `e-mail` is a phrase of two words just like `"e mail"`.
+func Parse(s string) Query {
+	var q Query
+
+	for i, part := range strings.Split(s, `"`) {
+		// odd parts were between quotes
+		if i%2 == 1 {
+			q.add(part)
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			q.add(field)
+		}
+	}
+
+	return q
+}
+
+func (q *Query) add(s string) {
+	s = strings.TrimSpace(s)
+	prefix := strings.HasSuffix(s, "*")
+
+	words := make([]string, 0, 1)
+	for _, t := range tokenize(s) {
+		words = append(words, t.word)
+	}
+	if len(words) == 0 {
+		return
+	}
+
+	q.Terms = append(q.Terms, Term{Words: words, Prefix: prefix})
+}
+
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0
+}
+
+// TSQuery renders q in to_tsquery syntax. Words only hold letters and
+// digits, so nothing needs quoting.
+func (q Query) TSQuery() string {
+	terms := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		term := strings.Join(t.Words, " <-> ")
+		if t.Prefix {
+			term += ":*"
+		}
+		if len(t.Words) > 1 {
+			term = "(" + term + ")"
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// Hit describes how a document matched a query.
+type Hit struct {
+	Rank    float64
+	Snippet string
+}
+
+// Match ranks a note against q the way Postgres ranks its weighted search
+// vector, a title hit counting more than a content hit, and builds a snippet
+// of the content. Unlike Postgres it does not stem words. It reports false
+// if a term occurs in neither the title nor the content.
+func (q Query) Match(title, content string) (Hit, bool) {
+	if q.Empty() {
+		return Hit{}, false
+	}
+
+	titleTokens := tokenize(title)
+	contentTokens := tokenize(content)
+
+	var hit Hit
+	marked := make([]bool, len(contentTokens))
+	first := -1
+
+	for _, t := range q.Terms {
+		titleHits := t.find(titleTokens, nil)
+		contentHits := t.find(contentTokens, marked)
+		if len(titleHits) == 0 && len(contentHits) == 0 {
+			return Hit{}, false
+		}
+
+		hit.Rank += titleWeight*float64(len(titleHits)) + contentWeight*float64(len(contentHits))
+		if len(contentHits) > 0 && (first == -1 || contentHits[0] < first) {
+			first = contentHits[0]
+		}
+	}
+
+	// longer documents rank lower for the same number of hits
+	hit.Rank /= float64(1 + len(titleTokens) + len(contentTokens))
+	hit.Snippet = snippet(content, contentTokens, marked, max(first, 0))
+
+	return hit, true
+}
+
+// Page orders results best match first, newer notes breaking ties, and cuts
+// out the requested page. It is meant for backends matching with Match.
+func Page(results []models.NoteSearchResult, limit, offset int) []models.NoteSearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+
+	if offset < 0 || offset >= len(results) || limit <= 0 {
+		return nil
+	}
+	return results[offset:min(offset+limit, len(results))]
+}
+
+// find returns the token positions where t starts and marks its words.
+func (t Term) find(tokens []token, marked []bool) []int {
+	var hits []int
+
+	for i := 0; i+len(t.Words) <= len(tokens); i++ {
+		if !t.matchAt(tokens, i) {
+			continue
+		}
+		hits = append(hits, i)
+		if marked != nil {
+			for j := range t.Words {
+				marked[i+j] = true
+			}
+		}
+	}
+
+	return hits
+}
+
+func (t Term) matchAt(tokens []token, i int) bool {
+	last := len(t.Words) - 1
+	for j, w := range t.Words {
+		word := tokens[i+j].word
+		if j == last && t.Prefix {
+			if !strings.HasPrefix(word, w) {
+				return false
+			}
+			continue
+		}
+		if word != w {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet cuts MaxWords words out of text around the token at start,
+// wrapping the marked tokens in StartSel and StopSel and escaping the rest.
+func snippet(text string, tokens []token, marked []bool, start int) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	// show a little context before the first hit
+	start = max(0, min(start-MaxWords/4, len(tokens)-MaxWords))
+	end := min(start+MaxWords, len(tokens))
+
+	var b strings.Builder
+	pos := tokens[start].from
+	for i := start; i < end; i++ {
+		t := tokens[i]
+		b.WriteString(html.EscapeString(text[pos:t.from]))
+		if marked[i] {
+			b.WriteString(StartSel + html.EscapeString(text[t.from:t.to]) + StopSel)
+		} else {
+			b.WriteString(html.EscapeString(text[t.from:t.to]))
+		}
+		pos = t.to
+	}
+
+	return b.String()
+}
+
+type token struct {
+	word     string
+	from, to int // byte offsets in the original text
+}
+
+func tokenize(s string) []token {
+	var tokens []token
+
+	from := -1
+	for i, r := range s {
+		wordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case wordRune && from == -1:
+			from = i
+		case !wordRune && from != -1:
+			tokens = append(tokens, token{word: strings.ToLower(s[from:i]), from: from, to: i})
+			from = -1
+		}
+	}
+	if from != -1 {
+		tokens = append(tokens, token{word: strings.ToLower(s[from:]), from: from, to: len(s)})
+	}
+
+	return tokens
+}
@@ -0,0 +1,22 @@
+package search
+
+import "testing"
+
+func TestFromHeadline(t *testing.T) {
+	got := FromHeadline(`<b>"a" & ` + HeadlineStartSel + "tomatoes" + HeadlineStopSel)
+	want := `&lt;b&gt;&#34;a&#34; &amp; ` + StartSel + "tomatoes" + StopSel
+	if got != want {
+		t.Fatalf("FromHeadline: got %q, want %q", got, want)
+	}
+}
+
+func TestMatchSnippetEscaped(t *testing.T) {
+	hit, ok := Parse("tomatoes").Match("", `a <b>"a" & tomatoes`)
+	if !ok {
+		t.Fatal("Match: no hit")
+	}
+	want := FromHeadline(`a <b>"a" & ` + HeadlineStartSel + "tomatoes" + HeadlineStopSel)
+	if hit.Snippet != want {
+		t.Fatalf("Match snippet: got %q, want %q", hit.Snippet, want)
+	}
+}
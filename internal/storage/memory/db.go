@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"strings"
+	"sync"
+	"time"
+)
+
+type user struct {
+	models.User
+	totp                models.TOTP
+	emailVerifiedAt     time.Time
+	tokensRevokedBefore time.Time
+}
+
+type refreshToken struct {
+	userId    int
+	familyId  string
+	expiresAt time.Time
+	used      bool
+	revoked   bool
+}
+
+type resetToken struct {
+	userId    int
+	expiresAt time.Time
+	used      bool
+}
+
+// DB keeps everything the repositories of this package store, guarded by a
+// single lock. It is meant for development and tests, nothing survives a restart.
+type DB struct {
+	mu sync.Mutex
+
+	users         map[int]*user
+	notes         map[int]models.Note
+	trashed       map[int]time.Time             // note to deletion time
+	revisions     map[int][]models.NoteRevision // note to revisions, oldest first
+	tags          map[int]map[string]bool       // user to tag names
+	notebooks     map[int]models.Notebook
+	refreshTokens map[string]*refreshToken // by token hash
+	revokedTokens map[string]time.Time     // jti to expiry
+	recoveryCodes map[int]map[string]bool  // user to code hash to used
+	resetTokens   map[string]*resetToken   // by token hash
+
+	lastUserID     int
+	lastNoteID     int
+	lastNotebookID int
+	lastRevisionID int
+}
+
+func NewDB() *DB {
+	return &DB{
+		users:         make(map[int]*user),
+		notes:         make(map[int]models.Note),
+		trashed:       make(map[int]time.Time),
+		revisions:     make(map[int][]models.NoteRevision),
+		tags:          make(map[int]map[string]bool),
+		notebooks:     make(map[int]models.Notebook),
+		refreshTokens: make(map[string]*refreshToken),
+		revokedTokens: make(map[string]time.Time),
+		recoveryCodes: make(map[int]map[string]bool),
+		resetTokens:   make(map[string]*resetToken),
+	}
+}
+
+// userByName must be called with the lock held.
+func (db *DB) userByName(username string) *user {
+	for _, u := range db.users {
+		if u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+// userByEmail must be called with the lock held.
+func (db *DB) userByEmail(email string) *user {
+	for _, u := range db.users {
+		if u.Email != "" && strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+// addTags registers tags for the user, must be called with the lock held.
+func (db *DB) addTags(userId int, tags []string) {
+	if db.tags[userId] == nil {
+		db.tags[userId] = make(map[string]bool)
+	}
+	for _, t := range tags {
+		db.tags[userId][t] = true
+	}
+}
+
+// isTrashed must be called with the lock held.
+func (db *DB) isTrashed(noteId int) bool {
+	_, ok := db.trashed[noteId]
+	return ok
+}
+
+// deleteNote removes a note for good, must be called with the lock held.
+func (db *DB) deleteNote(noteId int) {
+	delete(db.notes, noteId)
+	delete(db.trashed, noteId)
+	delete(db.revisions, noteId)
+}
+
+// checkNotebook validates a notebook referenced from a request body, must be
+// called with the lock held.
+func (db *DB) checkNotebook(userId int, notebookId *int) error {
+	if notebookId == nil {
+		return nil
+	}
+	if nb, ok := db.notebooks[*notebookId]; !ok || nb.UserID != userId {
+		return storage.ErrInvalidNotebook
+	}
+	return nil
+}
+
+// notebookTree returns the notebook with the ids of all notebooks nested
+// below it, must be called with the lock held.
+func (db *DB) notebookTree(notebookId int) map[int]bool {
+	tree := map[int]bool{notebookId: true}
+	for grew := true; grew; {
+		grew = false
+		for id, nb := range db.notebooks {
+			if nb.ParentID != nil && tree[*nb.ParentID] && !tree[id] {
+				tree[id] = true
+				grew = true
+			}
+		}
+	}
+	return tree
+}
@@ -0,0 +1,56 @@
+package memory_test
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/memory"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/storagetest"
+	"github/yusupovkuzs/GoNotesApp/pkg/password"
+	"testing"
+	"time"
+)
+
+func TestRepositories(t *testing.T) {
+	db := memory.NewDB()
+
+	storagetest.Run(t, storage.Repositories{
+		Notes:          memory.NewNoteRepoMemory(db, storagetest.MaxRevisions),
+		Tags:           memory.NewTagRepoMemory(db),
+		Notebooks:      memory.NewNotebookRepoMemory(db),
+		Revisions:      memory.NewRevisionRepoMemory(db),
+		Users:          memory.NewUserRepoMemory(db, storagetest.Hasher()),
+		RefreshTokens:  memory.NewRefreshTokenRepoMemory(db, 24*time.Hour),
+		MFA:            memory.NewMFARepoMemory(db),
+		PasswordResets: memory.NewPasswordResetRepoMemory(db, time.Hour),
+		Revocations:    memory.NewRevocationRepoMemory(db),
+	})
+}
+
+func TestAuthenticateRehashes(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+
+	weak := memory.NewUserRepoMemory(db, password.NewHasher(password.NewBcrypt(4)))
+	id, err := weak.CreateUser(ctx, models.User{Username: "alice", Password: "password123"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	strong := password.NewBcrypt(5)
+	users := memory.NewUserRepoMemory(db, password.NewHasher(strong))
+	if _, err = users.Authenticate(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	u, err := users.GetUserByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if strong.NeedsRehash(u.PasswordHash) {
+		t.Fatalf("login did not rehash the password, hash %q", u.PasswordHash)
+	}
+	if _, err = users.Authenticate(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("Authenticate with the new hash: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+)
+
+type MFARepoMemory struct {
+	db *DB
+}
+
+func NewMFARepoMemory(db *DB) *MFARepoMemory {
+	return &MFARepoMemory{db: db}
+}
+
+func (r *MFARepoMemory) GetTOTP(ctx context.Context, userId int) (models.TOTP, error) {
+	const op = "storage.memory.GetTOTP"
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return u.totp, nil
+}
+
+// SetPendingTOTPSecret stores a secret that only takes effect once EnableTOTP confirms it.
+func (r *MFARepoMemory) SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok || u.totp.Enabled {
+		return storage.ErrMFAAlreadyEnabled
+	}
+	u.totp.Secret = secret
+
+	return nil
+}
+
+// EnableTOTP turns on 2FA and replaces the user's recovery codes.
+func (r *MFARepoMemory) EnableTOTP(ctx context.Context, userId int, step int64, recoveryCodes []string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok || u.totp.Secret == "" || u.totp.Enabled {
+		return storage.ErrMFAAlreadyEnabled
+	}
+	u.totp.Enabled = true
+	u.totp.LastStep = step
+
+	codes := make(map[string]bool, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		codes[storage.HashToken(code)] = false
+	}
+	r.db.recoveryCodes[userId] = codes
+
+	return nil
+}
+
+func (r *MFARepoMemory) DisableTOTP(ctx context.Context, userId int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[userId]; ok {
+		u.totp = models.TOTP{}
+	}
+	delete(r.db.recoveryCodes, userId)
+
+	return nil
+}
+
+// UseTOTPStep records a successfully validated time step and reports false
+// if it, or a later one, was already used.
+func (r *MFARepoMemory) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok || u.totp.LastStep >= step {
+		return false, nil
+	}
+	u.totp.LastStep = step
+
+	return true, nil
+}
+
+// UseRecoveryCode consumes a recovery code and reports false if it is unknown or already used.
+func (r *MFARepoMemory) UseRecoveryCode(ctx context.Context, userId int, code string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	codes := r.db.recoveryCodes[userId]
+	hash := storage.HashToken(code)
+	if used, ok := codes[hash]; !ok || used {
+		return false, nil
+	}
+	codes[hash] = true
+
+	return true, nil
+}
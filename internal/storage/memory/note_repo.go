@@ -0,0 +1,336 @@
+package memory
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/search"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"slices"
+	"sort"
+	"time"
+)
+
+type NoteRepoMemory struct {
+	db *DB
+	// maxRevisions bounds the revisions kept per note, zero keeps them all.
+	maxRevisions int
+}
+
+func NewNoteRepoMemory(db *DB, maxRevisions int) *NoteRepoMemory {
+	return &NoteRepoMemory{db: db, maxRevisions: maxRevisions}
+}
+
+func (r *NoteRepoMemory) CreateNote(ctx context.Context, n models.Note) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := r.db.checkNotebook(n.UserID, n.NotebookID); err != nil {
+		return 0, err
+	}
+
+	r.db.lastNoteID++
+	now := time.Now()
+
+	n.ID = r.db.lastNoteID
+	n.Tags = sortedTags(n.Tags)
+	n.CreatedAt = now
+	n.UpdatedAt = now
+	r.db.notes[n.ID] = n
+	r.db.addTags(n.UserID, n.Tags)
+
+	return n.ID, nil
+}
+
+func (r *NoteRepoMemory) GetAllNotes(ctx context.Context, userId int, filter storage.NoteFilter) ([]models.NoteDTO, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var notebooks map[int]bool
+	if filter.NotebookID != 0 {
+		notebooks = map[int]bool{filter.NotebookID: true}
+		if filter.Descendants {
+			notebooks = r.db.notebookTree(filter.NotebookID)
+		}
+	}
+
+	var owned []models.Note
+	for _, n := range r.db.notes {
+		if n.UserID != userId || r.db.isTrashed(n.ID) || !matchTags(n.Tags, filter) {
+			continue
+		}
+		if notebooks != nil && (n.NotebookID == nil || !notebooks[*n.NotebookID]) {
+			continue
+		}
+		owned = append(owned, n)
+	}
+
+	sort.Slice(owned, func(i, j int) bool {
+		a, b := owned[i], owned[j]
+		if filter.Sort == "desc" {
+			a, b = b, a
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	var notes []models.NoteDTO
+	for i := filter.Offset; i >= 0 && i < len(owned) && len(notes) < filter.Limit; i++ {
+		n := owned[i]
+		notes = append(notes, models.NoteDTO{
+			ID:         n.ID,
+			Title:      n.Title,
+			Content:    n.Content,
+			Tags:       sortedTags(n.Tags),
+			NotebookID: n.NotebookID,
+			CreatedAt:  n.CreatedAt,
+			UpdatedAt:  n.UpdatedAt,
+		})
+	}
+
+	return notes, nil
+}
+
+func (r *NoteRepoMemory) SearchNotes(ctx context.Context, userId int, q search.Query, limit, offset int) ([]models.NoteSearchResult, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var results []models.NoteSearchResult
+	for _, n := range r.db.notes {
+		if n.UserID != userId || r.db.isTrashed(n.ID) {
+			continue
+		}
+
+		hit, ok := q.Match(n.Title, n.Content)
+		if !ok {
+			continue
+		}
+		results = append(results, models.NoteSearchResult{
+			NoteDTO: models.NoteDTO{
+				ID:         n.ID,
+				Title:      n.Title,
+				Content:    n.Content,
+				Tags:       sortedTags(n.Tags),
+				NotebookID: n.NotebookID,
+				CreatedAt:  n.CreatedAt,
+				UpdatedAt:  n.UpdatedAt,
+			},
+			Rank:    hit.Rank,
+			Snippet: hit.Snippet,
+		})
+	}
+
+	return search.Page(results, limit, offset), nil
+}
+
+func (r *NoteRepoMemory) GetNote(ctx context.Context, userId, noteId int) (models.Note, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n, err := r.note(userId, noteId, false)
+	n.Tags = sortedTags(n.Tags)
+	return n, err
+}
+
+func (r *NoteRepoMemory) UpdateNote(ctx context.Context, userId, noteId int, input models.UpdateNoteInput) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n, err := r.note(userId, noteId, false)
+	if err != nil {
+		return err
+	}
+
+	prev := n
+	if input.Title != nil {
+		n.Title = *input.Title
+	}
+	if input.Content != nil {
+		n.Content = *input.Content
+	}
+	if input.Tags != nil {
+		n.Tags = sortedTags(*input.Tags)
+		r.db.addTags(userId, n.Tags)
+	}
+	n.UpdatedAt = time.Now()
+	r.db.notes[noteId] = n
+
+	if n.Title != prev.Title || n.Content != prev.Content {
+		r.addRevision(userId, prev, n.UpdatedAt)
+	}
+
+	return nil
+}
+
+func (r *NoteRepoMemory) MoveNote(ctx context.Context, userId, noteId int, notebookId *int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n, err := r.note(userId, noteId, false)
+	if err != nil {
+		return err
+	}
+	if err = r.db.checkNotebook(userId, notebookId); err != nil {
+		return err
+	}
+
+	n.NotebookID = notebookId
+	r.db.notes[noteId] = n
+
+	return nil
+}
+
+func (r *NoteRepoMemory) DeleteNote(ctx context.Context, userId, noteId int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, err := r.note(userId, noteId, false); err != nil {
+		return err
+	}
+	r.db.trashed[noteId] = time.Now()
+
+	return nil
+}
+
+func (r *NoteRepoMemory) GetTrash(ctx context.Context, userId, limit, offset int) ([]models.NoteDTO, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var trashed []models.Note
+	for id, n := range r.db.notes {
+		if n.UserID == userId && r.db.isTrashed(id) {
+			trashed = append(trashed, n)
+		}
+	}
+
+	sort.Slice(trashed, func(i, j int) bool {
+		a, b := r.db.trashed[trashed[i].ID], r.db.trashed[trashed[j].ID]
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return trashed[i].ID > trashed[j].ID
+	})
+
+	var notes []models.NoteDTO
+	for i := offset; i >= 0 && i < len(trashed) && len(notes) < limit; i++ {
+		n := trashed[i]
+		deletedAt := r.db.trashed[n.ID]
+		notes = append(notes, models.NoteDTO{
+			ID:         n.ID,
+			Title:      n.Title,
+			Content:    n.Content,
+			Tags:       sortedTags(n.Tags),
+			NotebookID: n.NotebookID,
+			CreatedAt:  n.CreatedAt,
+			UpdatedAt:  n.UpdatedAt,
+			DeletedAt:  &deletedAt,
+		})
+	}
+
+	return notes, nil
+}
+
+func (r *NoteRepoMemory) RestoreNote(ctx context.Context, userId, noteId int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, err := r.note(userId, noteId, true); err != nil {
+		return err
+	}
+	delete(r.db.trashed, noteId)
+
+	return nil
+}
+
+func (r *NoteRepoMemory) PurgeNote(ctx context.Context, userId, noteId int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, err := r.note(userId, noteId, true); err != nil {
+		return err
+	}
+	r.db.deleteNote(noteId)
+
+	return nil
+}
+
+func (r *NoteRepoMemory) PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	purged := 0
+	for id, deletedAt := range r.db.trashed {
+		if purged == limit {
+			break
+		}
+		if deletedAt.Before(deletedBefore) {
+			r.db.deleteNote(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// addRevision keeps prev as a revision of the note, dropping the oldest ones
+// beyond maxRevisions. It must be called with the lock held.
+func (r *NoteRepoMemory) addRevision(authorId int, prev models.Note, at time.Time) {
+	r.db.lastRevisionID++
+	revisions := append(r.db.revisions[prev.ID], models.NoteRevision{
+		ID:        r.db.lastRevisionID,
+		NoteID:    prev.ID,
+		AuthorID:  authorId,
+		Title:     prev.Title,
+		Content:   prev.Content,
+		CreatedAt: at,
+	})
+	if r.maxRevisions > 0 && len(revisions) > r.maxRevisions {
+		revisions = slices.Clone(revisions[len(revisions)-r.maxRevisions:])
+	}
+	r.db.revisions[prev.ID] = revisions
+}
+
+// note looks a note up on either side of the trash, a note on the other side
+// counts as missing. It must be called with the lock held.
+func (r *NoteRepoMemory) note(userId, noteId int, trashed bool) (models.Note, error) {
+	n, ok := r.db.notes[noteId]
+	if !ok || r.db.isTrashed(noteId) != trashed {
+		return models.Note{}, storage.ErrNotFound
+	}
+	if n.UserID != userId {
+		return models.Note{}, storage.ErrAccessDenied
+	}
+
+	return n, nil
+}
+
+// matchTags reports whether a note's tags pass the filter's tag condition.
+func matchTags(tags []string, filter storage.NoteFilter) bool {
+	if len(filter.Tags) == 0 {
+		return true
+	}
+
+	matched := 0
+	for _, t := range filter.Tags {
+		if slices.Contains(tags, t) {
+			matched++
+		}
+	}
+
+	if filter.MatchAllTags {
+		return matched == len(filter.Tags)
+	}
+	return matched > 0
+}
+
+// sortedTags copies tags in the order the SQL backends return them, so
+// stored notes never share a slice with callers.
+func sortedTags(tags []string) []string {
+	sorted := slices.Clone(tags)
+	if sorted == nil {
+		sorted = []string{}
+	}
+	slices.Sort(sorted)
+	return sorted
+}
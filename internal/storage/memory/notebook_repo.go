@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"sort"
+	"time"
+)
+
+type NotebookRepoMemory struct {
+	db *DB
+}
+
+func NewNotebookRepoMemory(db *DB) *NotebookRepoMemory {
+	return &NotebookRepoMemory{db: db}
+}
+
+func (r *NotebookRepoMemory) CreateNotebook(ctx context.Context, nb models.Notebook) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := r.db.checkNotebook(nb.UserID, nb.ParentID); err != nil {
+		return 0, err
+	}
+
+	r.db.lastNotebookID++
+	now := time.Now()
+
+	nb.ID = r.db.lastNotebookID
+	nb.CreatedAt = now
+	nb.UpdatedAt = now
+	r.db.notebooks[nb.ID] = nb
+
+	return nb.ID, nil
+}
+
+func (r *NotebookRepoMemory) GetNotebooks(ctx context.Context, userId int) ([]models.Notebook, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	notebooks := []models.Notebook{}
+	for _, nb := range r.db.notebooks {
+		if nb.UserID == userId {
+			notebooks = append(notebooks, nb)
+		}
+	}
+
+	sort.Slice(notebooks, func(i, j int) bool {
+		a, b := notebooks[i], notebooks[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+
+	return notebooks, nil
+}
+
+func (r *NotebookRepoMemory) GetNotebook(ctx context.Context, userId, notebookId int) (models.Notebook, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.notebook(userId, notebookId)
+}
+
+func (r *NotebookRepoMemory) RenameNotebook(ctx context.Context, userId, notebookId int, name string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	nb, err := r.notebook(userId, notebookId)
+	if err != nil {
+		return err
+	}
+
+	nb.Name = name
+	nb.UpdatedAt = time.Now()
+	r.db.notebooks[notebookId] = nb
+
+	return nil
+}
+
+func (r *NotebookRepoMemory) MoveNotebook(ctx context.Context, userId, notebookId int, parentId *int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	nb, err := r.notebook(userId, notebookId)
+	if err != nil {
+		return err
+	}
+	if err = r.db.checkNotebook(userId, parentId); err != nil {
+		return err
+	}
+	if parentId != nil && r.db.notebookTree(notebookId)[*parentId] {
+		return storage.ErrNotebookCycle
+	}
+
+	nb.ParentID = parentId
+	nb.UpdatedAt = time.Now()
+	r.db.notebooks[notebookId] = nb
+
+	return nil
+}
+
+func (r *NotebookRepoMemory) DeleteNotebook(ctx context.Context, userId, notebookId int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, err := r.notebook(userId, notebookId); err != nil {
+		return err
+	}
+
+	tree := r.db.notebookTree(notebookId)
+	for id := range tree {
+		delete(r.db.notebooks, id)
+	}
+	for id, n := range r.db.notes {
+		if n.NotebookID != nil && tree[*n.NotebookID] {
+			n.NotebookID = nil
+			r.db.notes[id] = n
+		}
+	}
+
+	return nil
+}
+
+// notebook must be called with the lock held.
+func (r *NotebookRepoMemory) notebook(userId, notebookId int) (models.Notebook, error) {
+	nb, ok := r.db.notebooks[notebookId]
+	if !ok {
+		return models.Notebook{}, storage.ErrNotFound
+	}
+	if nb.UserID != userId {
+		return models.Notebook{}, storage.ErrAccessDenied
+	}
+
+	return nb, nil
+}
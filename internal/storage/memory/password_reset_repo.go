@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type PasswordResetRepoMemory struct {
+	db  *DB
+	ttl time.Duration
+}
+
+func NewPasswordResetRepoMemory(db *DB, ttl time.Duration) *PasswordResetRepoMemory {
+	return &PasswordResetRepoMemory{db: db, ttl: ttl}
+}
+
+// CreateResetToken issues a reset token and invalidates the ones requested before it.
+func (r *PasswordResetRepoMemory) CreateResetToken(ctx context.Context, userId int) (string, error) {
+	const op = "storage.memory.CreateResetToken"
+
+	token, err := storage.RandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for hash, t := range r.db.resetTokens {
+		if t.userId == userId {
+			delete(r.db.resetTokens, hash)
+		}
+	}
+	r.db.resetTokens[storage.HashToken(token)] = &resetToken{
+		userId:    userId,
+		expiresAt: time.Now().Add(r.ttl),
+	}
+
+	return token, nil
+}
+
+// ConsumeResetToken marks the token as used and returns the user it was issued to.
+func (r *PasswordResetRepoMemory) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.resetTokens[storage.HashToken(token)]
+	if !ok || t.used || !time.Now().Before(t.expiresAt) {
+		return 0, storage.ErrInvalidResetToken
+	}
+	t.used = true
+
+	return t.userId, nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RefreshTokenRepoMemory struct {
+	db  *DB
+	ttl time.Duration
+}
+
+func NewRefreshTokenRepoMemory(db *DB, ttl time.Duration) *RefreshTokenRepoMemory {
+	return &RefreshTokenRepoMemory{db: db, ttl: ttl}
+}
+
+// CreateRefreshToken starts a new token family for the user and returns its first token.
+func (r *RefreshTokenRepoMemory) CreateRefreshToken(ctx context.Context, userId int) (string, error) {
+	const op = "storage.memory.CreateRefreshToken"
+
+	familyId, err := storage.RandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	token, err := r.insertRefreshToken(userId, familyId)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same family.
+// Presenting a token that was already rotated revokes the whole family.
+func (r *RefreshTokenRepoMemory) RotateRefreshToken(ctx context.Context, token string) (int, string, error) {
+	const op = "storage.memory.RotateRefreshToken"
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.refreshTokens[storage.HashToken(token)]
+	if !ok {
+		return 0, "", storage.ErrInvalidRefreshToken
+	}
+
+	if t.used {
+		r.revokeFamily(t.familyId)
+		return 0, "", storage.ErrRefreshTokenReused
+	}
+	if t.revoked || time.Now().After(t.expiresAt) {
+		return 0, "", storage.ErrInvalidRefreshToken
+	}
+
+	newToken, err := r.insertRefreshToken(t.userId, t.familyId)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+	t.used = true
+
+	return t.userId, newToken, nil
+}
+
+// RevokeRefreshToken revokes the family of a refresh token owned by the user.
+func (r *RefreshTokenRepoMemory) RevokeRefreshToken(ctx context.Context, userId int, token string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.refreshTokens[storage.HashToken(token)]
+	if !ok || t.userId != userId || !r.revokeFamily(t.familyId) {
+		return storage.ErrInvalidRefreshToken
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepoMemory) RevokeUserRefreshTokens(ctx context.Context, userId int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.refreshTokens {
+		if t.userId == userId {
+			t.revoked = true
+		}
+	}
+
+	return nil
+}
+
+// insertRefreshToken must be called with the lock held.
+func (r *RefreshTokenRepoMemory) insertRefreshToken(userId int, familyId string) (string, error) {
+	token, err := storage.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	r.db.refreshTokens[storage.HashToken(token)] = &refreshToken{
+		userId:    userId,
+		familyId:  familyId,
+		expiresAt: time.Now().Add(r.ttl),
+	}
+
+	return token, nil
+}
+
+// revokeFamily must be called with the lock held. It reports whether any token was still active.
+func (r *RefreshTokenRepoMemory) revokeFamily(familyId string) bool {
+	var revoked bool
+	for _, t := range r.db.refreshTokens {
+		if t.familyId == familyId && !t.revoked {
+			t.revoked = true
+			revoked = true
+		}
+	}
+
+	return revoked
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+)
+
+type RevisionRepoMemory struct {
+	db *DB
+}
+
+func NewRevisionRepoMemory(db *DB) *RevisionRepoMemory {
+	return &RevisionRepoMemory{db: db}
+}
+
+func (r *RevisionRepoMemory) GetRevisions(ctx context.Context, userId, noteId, limit, offset int) ([]models.NoteRevision, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := r.checkNote(userId, noteId); err != nil {
+		return nil, err
+	}
+
+	stored := r.db.revisions[noteId]
+	revisions := []models.NoteRevision{}
+	// stored oldest first, listed newest first
+	for i := len(stored) - 1 - offset; offset >= 0 && i >= 0 && len(revisions) < limit; i-- {
+		revisions = append(revisions, stored[i])
+	}
+
+	return revisions, nil
+}
+
+func (r *RevisionRepoMemory) GetRevision(ctx context.Context, userId, noteId, revisionId int) (models.NoteRevision, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := r.checkNote(userId, noteId); err != nil {
+		return models.NoteRevision{}, err
+	}
+
+	for _, rev := range r.db.revisions[noteId] {
+		if rev.ID == revisionId {
+			return rev, nil
+		}
+	}
+
+	return models.NoteRevision{}, storage.ErrNotFound
+}
+
+// checkNote must be called with the lock held.
+func (r *RevisionRepoMemory) checkNote(userId, noteId int) error {
+	n, ok := r.db.notes[noteId]
+	if !ok || r.db.isTrashed(noteId) {
+		return storage.ErrNotFound
+	}
+	if n.UserID != userId {
+		return storage.ErrAccessDenied
+	}
+	return nil
+}
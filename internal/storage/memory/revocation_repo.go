@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+type RevocationRepoMemory struct {
+	db *DB
+}
+
+func NewRevocationRepoMemory(db *DB) *RevocationRepoMemory {
+	return &RevocationRepoMemory{db: db}
+}
+
+func (r *RevocationRepoMemory) RevokeToken(ctx context.Context, jti string, userId int, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	r.db.revokedTokens[jti] = expiresAt
+
+	// expired tokens are rejected anyway, no need to remember them
+	for id, exp := range r.db.revokedTokens {
+		if exp.Before(now) {
+			delete(r.db.revokedTokens, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *RevocationRepoMemory) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	_, ok := r.db.revokedTokens[jti]
+	return ok, nil
+}
+
+// RevokeAllTokens invalidates every token issued to the user before now.
+func (r *RevocationRepoMemory) RevokeAllTokens(ctx context.Context, userId int) (time.Time, error) {
+	// iat has a one second resolution, tokens issued later within the same second stay valid
+	before := time.Now().Truncate(time.Second)
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[userId]; ok {
+		u.tokensRevokedBefore = before
+	}
+
+	return before, nil
+}
+
+// TokensRevokedBefore returns the user's revocation watermark, zero if there is none.
+func (r *RevocationRepoMemory) TokensRevokedBefore(ctx context.Context, userId int) (time.Time, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[userId]; ok {
+		return u.tokensRevokedBefore, nil
+	}
+
+	return time.Time{}, nil
+}
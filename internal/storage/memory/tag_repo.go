@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"slices"
+	"sort"
+)
+
+type TagRepoMemory struct {
+	db *DB
+}
+
+func NewTagRepoMemory(db *DB) *TagRepoMemory {
+	return &TagRepoMemory{db: db}
+}
+
+func (r *TagRepoMemory) ListTags(ctx context.Context, userId int) ([]models.Tag, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	counts := make(map[string]int)
+	for name := range r.db.tags[userId] {
+		counts[name] = 0
+	}
+	for id, n := range r.db.notes {
+		if n.UserID != userId || r.db.isTrashed(id) {
+			continue
+		}
+		for _, t := range n.Tags {
+			counts[t]++
+		}
+	}
+
+	tags := make([]models.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, models.Tag{Name: name, NoteCount: count})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+func (r *TagRepoMemory) RenameTag(ctx context.Context, userId int, name, newName string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	userTags := r.db.tags[userId]
+	if !userTags[name] {
+		return storage.ErrNotFound
+	}
+	if name == newName {
+		return nil
+	}
+	if userTags[newName] {
+		return storage.ErrTagExists
+	}
+
+	r.replaceTag(userId, name, newName)
+	return nil
+}
+
+func (r *TagRepoMemory) MergeTag(ctx context.Context, userId int, name, into string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if !r.db.tags[userId][name] {
+		return storage.ErrNotFound
+	}
+	if name == into {
+		return nil
+	}
+
+	r.replaceTag(userId, name, into)
+	return nil
+}
+
+func (r *TagRepoMemory) DeleteTag(ctx context.Context, userId int, name string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if !r.db.tags[userId][name] {
+		return storage.ErrNotFound
+	}
+
+	r.replaceTag(userId, name, "")
+	return nil
+}
+
+// replaceTag swaps name for with on the user's notes, dropping it when with
+// is empty. Must be called with the lock held.
+func (r *TagRepoMemory) replaceTag(userId int, name, with string) {
+	delete(r.db.tags[userId], name)
+	if with != "" {
+		r.db.addTags(userId, []string{with})
+	}
+
+	for id, n := range r.db.notes {
+		if n.UserID != userId || !slices.Contains(n.Tags, name) {
+			continue
+		}
+
+		tags := make([]string, 0, len(n.Tags))
+		for _, t := range n.Tags {
+			if t != name && t != with {
+				tags = append(tags, t)
+			}
+		}
+		if with != "" {
+			tags = append(tags, with)
+		}
+		n.Tags = tags
+		r.db.notes[id] = n
+	}
+}
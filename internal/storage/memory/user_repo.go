@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/password"
+	"strings"
+	"time"
+)
+
+type UserRepoMemory struct {
+	db     *DB
+	hasher *password.Hasher
+}
+
+func NewUserRepoMemory(db *DB, hasher *password.Hasher) *UserRepoMemory {
+	return &UserRepoMemory{db: db, hasher: hasher}
+}
+
+func (r *UserRepoMemory) CreateUser(ctx context.Context, u models.User) (int, error) {
+	const op = "storage.memory.CreateUser"
+
+	hash, err := r.hasher.Hash(u.Password)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.userByName(u.Username) != nil {
+		return 0, storage.ErrUsernameTaken
+	}
+	if u.Email != "" && r.db.userByEmail(u.Email) != nil {
+		return 0, storage.ErrEmailTaken
+	}
+
+	r.db.lastUserID++
+	r.db.users[r.db.lastUserID] = &user{
+		User: models.User{
+			ID:           r.db.lastUserID,
+			Username:     u.Username,
+			Email:        u.Email,
+			CreatedAt:    time.Now(),
+			PasswordHash: hash,
+		},
+	}
+
+	return r.db.lastUserID, nil
+}
+
+func (r *UserRepoMemory) GetUser(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.memory.GetUser"
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.userByName(username)
+	if u == nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return u.view(), nil
+}
+
+func (r *UserRepoMemory) GetUserByID(ctx context.Context, userId int) (models.User, error) {
+	const op = "storage.memory.GetUserByID"
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return u.view(), nil
+}
+
+// MarkEmailVerified verifies the user's email, provided it hasn't changed since the link was sent.
+func (r *UserRepoMemory) MarkEmailVerified(ctx context.Context, userId int, email string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok || u.Email == "" || !strings.EqualFold(u.Email, email) {
+		return storage.ErrInvalidVerificationToken
+	}
+	if u.emailVerifiedAt.IsZero() {
+		u.emailVerifiedAt = time.Now()
+	}
+
+	return nil
+}
+
+// VerifyPassword re-confirms the password of an already authenticated user.
+func (r *UserRepoMemory) VerifyPassword(ctx context.Context, userId int, password string) error {
+	const op = "storage.memory.VerifyPassword"
+
+	user, err := r.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return storage.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (r *UserRepoMemory) UpdatePasswordHash(ctx context.Context, userId int, hash string) error {
+	const op = "storage.memory.UpdatePasswordHash"
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userId]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	u.PasswordHash = hash
+
+	return nil
+}
+
+// UpdatePassword replaces the user's password with a hash of the new one.
+func (r *UserRepoMemory) UpdatePassword(ctx context.Context, userId int, password string) error {
+	const op = "storage.memory.UpdatePassword"
+
+	hash, err := r.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r.UpdatePasswordHash(ctx, userId, hash)
+}
+
+// Authenticate checks the user's password and upgrades its hash when it was
+// produced by an outdated scheme.
+func (r *UserRepoMemory) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	const op = "storage.memory.Authenticate"
+
+	user, err := r.GetUser(ctx, username)
+	if err != nil {
+		r.hasher.VerifyDummy(password)
+		return models.User{}, storage.ErrInvalidCredentials
+	}
+
+	ok, err := r.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return models.User{}, storage.ErrInvalidCredentials
+	}
+
+	if r.hasher.NeedsRehash(user.PasswordHash) {
+		if hash, err := r.hasher.Hash(password); err == nil {
+			if err = r.UpdatePasswordHash(ctx, user.ID, hash); err == nil {
+				user.PasswordHash = hash
+			}
+		}
+	}
+
+	return user, nil
+}
+
+// view copies the user the way the postgres repository would read it.
+func (u *user) view() models.User {
+	v := u.User
+	v.TOTPEnabled = u.totp.Enabled
+	v.EmailVerified = !u.emailVerifiedAt.IsZero()
+	return v
+}
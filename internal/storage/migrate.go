@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/migrations"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migrator applies the embedded schema migrations of one backend.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// NewMigrator prepares the migrations for driver. On Postgres every run holds
+// an advisory lock, so replicas starting together don't migrate concurrently.
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	var (
+		dialect goose.Dialect
+		fsys    fs.FS = migrations.FS
+		opts    []goose.ProviderOption
+	)
+
+	switch driver {
+	case "postgres":
+		dialect = goose.DialectPostgres
+
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, goose.WithSessionLocker(locker))
+	case "sqlite":
+		// a single file has a single writer, there is nothing to lock against
+		dialect = goose.DialectSQLite3
+
+		sub, err := fs.Sub(migrations.FS, "sqlite")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	default:
+		return nil, fmt.Errorf("storage driver %q has no migrations", driver)
+	}
+
+	provider, err := goose.NewProvider(dialect, db, fsys, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// To migrates up or down until the schema is at version.
+func (m *Migrator) To(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	current, err := m.provider.GetDBVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case version < current:
+		return m.provider.DownTo(ctx, version)
+	case version > current:
+		return m.provider.UpTo(ctx, version)
+	default:
+		return nil, nil
+	}
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Check fails unless the schema is at the latest embedded migration.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, target, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if current != target {
+		return fmt.Errorf("schema is at version %d, expected %d", current, target)
+	}
+
+	return nil
+}
+
+// IsNoChange reports whether err only says there was nothing to roll back.
+func IsNoChange(err error) bool {
+	return errors.Is(err, goose.ErrNoNextVersion)
+}
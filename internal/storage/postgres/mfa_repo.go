@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type MFARepoPostgres struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewMFARepoPostgres(db *sql.DB, timeout time.Duration) *MFARepoPostgres {
+	return &MFARepoPostgres{db: db, timeout: timeout}
+}
+
+func (r *MFARepoPostgres) GetTOTP(ctx context.Context, userId int) (models.TOTP, error) {
+	const op = "storage.postgres.GetTOTP"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	var (
+		totp   models.TOTP
+		secret sql.NullString
+	)
+
+	query := fmt.Sprintf(
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM %s WHERE id = $1",
+		storage.UsersTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, userId).Scan(&secret, &totp.Enabled, &totp.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	totp.Secret = secret.String
+	return totp, nil
+}
+
+// SetPendingTOTPSecret stores a secret that only takes effect once EnableTOTP confirms it.
+func (r *MFARepoPostgres) SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error {
+	const op = "storage.postgres.SetPendingTOTPSecret"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_secret = $1 WHERE id = $2 AND NOT totp_enabled",
+		storage.UsersTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, secret, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on 2FA and replaces the user's recovery codes.
+func (r *MFARepoPostgres) EnableTOTP(ctx context.Context, userId int, step int64, recoveryCodes []string) error {
+	const op = "storage.postgres.EnableTOTP"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET totp_enabled = true, totp_last_step = $1
+		 WHERE id = $2 AND totp_secret IS NOT NULL AND NOT totp_enabled`,
+		storage.UsersTable,
+	)
+	res, err := tx.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrMFAAlreadyEnabled
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", storage.RecoveryCodesTable)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s (user_id, code_hash) VALUES ($1, $2)", storage.RecoveryCodesTable)
+	for _, code := range recoveryCodes {
+		if _, err = tx.ExecContext(ctx, query, userId, storage.HashToken(code)); err != nil {
+			return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *MFARepoPostgres) DisableTOTP(ctx context.Context, userId int) error {
+	const op = "storage.postgres.DisableTOTP"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0 WHERE id = $1",
+		storage.UsersTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", storage.RecoveryCodesTable)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+// UseTOTPStep records a successfully validated time step and reports false
+// if it, or a later one, was already used.
+func (r *MFARepoPostgres) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	const op = "storage.postgres.UseTOTPStep"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1",
+		storage.UsersTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return n == 1, nil
+}
+
+// UseRecoveryCode consumes a recovery code and reports false if it is unknown or already used.
+func (r *MFARepoPostgres) UseRecoveryCode(ctx context.Context, userId int, code string) (bool, error) {
+	const op = "storage.postgres.UseRecoveryCode"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		`UPDATE %[1]s SET used_at = now()
+		 WHERE id = (
+		     SELECT id FROM %[1]s WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL LIMIT 1
+		 )`,
+		storage.RecoveryCodesTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, userId, storage.HashToken(code))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return n == 1, nil
+}
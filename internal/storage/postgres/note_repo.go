@@ -1,64 +1,142 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/search"
 	"github/yusupovkuzs/GoNotesApp/internal/storage"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
-type NoteRepository interface {
-	CreateNote(note models.Note) (int, error)
-	GetAllNotes(userId, limit, offset int, sort string) ([]models.NoteDTO, error)
-	GetNote(userId, noteId int) (models.NoteDTO, error)
-	UpdateNote(userId, noteId int, note models.Note) (int, error)
-	DeleteNote(userId, noteId int) error
-}
+// headlineOptions makes ts_headline cut snippets like the search package
+// does, search.FromHeadline escapes them.
+var headlineOptions = fmt.Sprintf(
+	"StartSel=%s, StopSel=%s, MaxWords=%d, MinWords=%d",
+	search.HeadlineStartSel, search.HeadlineStopSel, search.MaxWords, search.MaxWords/2,
+)
+
+// noteTagsColumn selects the sorted tag names of the note aliased n.
+var noteTagsColumn = fmt.Sprintf(
+	"ARRAY(SELECT t.name FROM %s nt JOIN %s t ON t.id = nt.tag_id WHERE nt.note_id = n.id ORDER BY t.name)",
+	storage.NoteTagsTable, storage.TagsTable,
+)
 
 type NoteRepoPostgres struct {
 	db *sql.DB
+	// searchLanguage is the text search configuration notes are indexed and searched with.
+	searchLanguage string
+	// maxRevisions bounds the revisions kept per note, zero keeps them all.
+	maxRevisions int
+	timeout      time.Duration
 }
 
-func NewNoteRepoPostgres(db *sql.DB) *NoteRepoPostgres {
-	return &NoteRepoPostgres{db: db}
+func NewNoteRepoPostgres(db *sql.DB, searchLanguage string, maxRevisions int, timeout time.Duration) *NoteRepoPostgres {
+	return &NoteRepoPostgres{db: db, searchLanguage: searchLanguage, maxRevisions: maxRevisions, timeout: timeout}
 }
 
-func (r *NoteRepoPostgres) CreateNote(n models.Note) (int, error) {
-	const op = "storage.postgres.GetUser"
+func (r *NoteRepoPostgres) CreateNote(ctx context.Context, n models.Note) (int, error) {
+	const op = "storage.postgres.CreateNote"
+
+	ctx, end := startQuery(ctx, op, "INSERT", r.timeout)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	if err = checkNotebook(ctx, tx, n.UserID, n.NotebookID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 
 	var id int
 
 	query := fmt.Sprintf(
-		"INSERT INTO %s (user_id, title, content) VALUES ($1, $2, $3) RETURNING id",
+		"INSERT INTO %s (user_id, title, content, notebook_id, search_language) VALUES ($1, $2, $3, $4, $5) RETURNING id",
 		storage.NotesTable,
 	)
-	row := r.db.QueryRow(query, n.UserID, n.Title, n.Content)
+	row := tx.QueryRowContext(ctx, query, n.UserID, n.Title, n.Content, n.NotebookID, r.searchLanguage)
 	if err := row.Scan(&id); err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = setNoteTags(ctx, tx, n.UserID, id, n.Tags); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 
 	return id, nil
 }
 
-func (r *NoteRepoPostgres) GetAllNotes(userId, limit, offset int, sort string) ([]models.NoteDTO, error) {
+func (r *NoteRepoPostgres) GetAllNotes(ctx context.Context, userId int, filter storage.NoteFilter) ([]models.NoteDTO, error) {
 	const op = "storage.postgres.GetAllNotes"
 
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
 	var notes []models.NoteDTO
 
+	conditions := []string{"n.user_id = $1", "n.deleted_at IS NULL"}
+	args := []interface{}{userId}
+
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags))
+		tagged := fmt.Sprintf(
+			`FROM %s nt JOIN %s t ON t.id = nt.tag_id
+			 WHERE nt.note_id = n.id AND t.name = ANY($%d)`,
+			storage.NoteTagsTable, storage.TagsTable, len(args),
+		)
+		if filter.MatchAllTags {
+			args = append(args, len(filter.Tags))
+			conditions = append(conditions, fmt.Sprintf("(SELECT count(*) %s) = $%d", tagged, len(args)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 %s)", tagged))
+		}
+	}
+
+	if filter.NotebookID != 0 {
+		args = append(args, filter.NotebookID)
+		if filter.Descendants {
+			conditions = append(conditions, fmt.Sprintf(
+				`n.notebook_id IN (
+				     WITH RECURSIVE tree (id) AS (
+				         SELECT id FROM %[1]s WHERE id = $%[2]d
+				         UNION
+				         SELECT nb.id FROM %[1]s nb JOIN tree ON nb.parent_id = tree.id
+				     )
+				     SELECT id FROM tree
+				 )`,
+				storage.NotebooksTable, len(args),
+			))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("n.notebook_id = $%d", len(args)))
+		}
+	}
+
 	query := fmt.Sprintf(
-		`SELECT id, title, content, created_at, updated_at 
-				FROM %s 
-				WHERE user_id = $1 
-				ORDER BY created_at %s
-				LIMIT $2 OFFSET $3 `,
-		storage.NotesTable, sort,
+		`SELECT n.id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at 
+				FROM %s n 
+				WHERE %s 
+				ORDER BY n.created_at %s
+				LIMIT $%d OFFSET $%d `,
+		noteTagsColumn, storage.NotesTable, strings.Join(conditions, " AND "), filter.Sort,
+		len(args)+1, len(args)+2,
 	)
+	args = append(args, filter.Limit, filter.Offset)
 
-	rows, err := r.db.Query(query, userId, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 
 	defer rows.Close()
@@ -66,25 +144,76 @@ func (r *NoteRepoPostgres) GetAllNotes(userId, limit, offset int, sort string) (
 	for rows.Next() {
 		var n models.NoteDTO
 
-		err = rows.Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt)
+		err = rows.Scan(&n.ID, &n.Title, &n.Content, pq.Array(&n.Tags), &n.NotebookID, &n.CreatedAt, &n.UpdatedAt)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 		}
 
 		notes = append(notes, n)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 
 	return notes, nil
 }
 
-func (r *NoteRepoPostgres) GetNote(userId, noteId int) (models.Note, error) {
+// SearchNotes ranks title matches above content matches through the weights
+// of the search vector and highlights the content with ts_headline.
+func (r *NoteRepoPostgres) SearchNotes(ctx context.Context, userId int, q search.Query, limit, offset int) ([]models.NoteSearchResult, error) {
+	const op = "storage.postgres.SearchNotes"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	var notes []models.NoteSearchResult
+
+	query := fmt.Sprintf(
+		`SELECT n.id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at,
+		        ts_rank(n.search_vector, q.query) AS rank,
+		        ts_headline($1::regconfig, translate(n.content, $7, ''), q.query, $2)
+		 FROM %s n, to_tsquery($1::regconfig, $3) AS q(query)
+		 WHERE n.user_id = $4 AND n.deleted_at IS NULL AND n.search_vector @@ q.query
+		 ORDER BY rank DESC, n.created_at DESC
+		 LIMIT $5 OFFSET $6`,
+		noteTagsColumn, storage.NotesTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query,
+		r.searchLanguage, headlineOptions, q.TSQuery(), userId, limit, offset,
+		search.HeadlineStartSel+search.HeadlineStopSel,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.NoteSearchResult
+
+		err = rows.Scan(&n.ID, &n.Title, &n.Content, pq.Array(&n.Tags), &n.NotebookID, &n.CreatedAt, &n.UpdatedAt, &n.Rank, &n.Snippet)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+		n.Snippet = search.FromHeadline(n.Snippet)
+
+		notes = append(notes, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return notes, nil
+}
+
+func (r *NoteRepoPostgres) GetNote(ctx context.Context, userId, noteId int) (models.Note, error) {
 	const op = "storage.postgres.GetNote"
 
-	err := r.validateId(userId, noteId)
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	err := validateNoteId(ctx, r.db, userId, noteId, false)
 	if err != nil {
 		return models.Note{}, err
 	}
@@ -93,13 +222,13 @@ func (r *NoteRepoPostgres) GetNote(userId, noteId int) (models.Note, error) {
 	n.ID = noteId
 
 	query := fmt.Sprintf(
-		"SELECT user_id, title, content, created_at, updated_at FROM %s WHERE id = $1",
-		storage.NotesTable,
+		"SELECT n.user_id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at FROM %s n WHERE n.id = $1",
+		noteTagsColumn, storage.NotesTable,
 	)
 
-	row := r.db.QueryRow(query, noteId)
-	if err = row.Scan(&n.UserID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt); err != nil {
-		return models.Note{}, fmt.Errorf("%s: %w", op, err)
+	row := r.db.QueryRowContext(ctx, query, noteId)
+	if err = row.Scan(&n.UserID, &n.Title, &n.Content, pq.Array(&n.Tags), &n.NotebookID, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		return models.Note{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 	if n.UserID != userId {
 		return models.Note{}, fmt.Errorf("%s: note is not owned by %d", op, userId)
@@ -108,13 +237,11 @@ func (r *NoteRepoPostgres) GetNote(userId, noteId int) (models.Note, error) {
 	return n, nil
 }
 
-func (r *NoteRepoPostgres) UpdateNote(userId, noteId int, note models.UpdateNoteInput) error {
+func (r *NoteRepoPostgres) UpdateNote(ctx context.Context, userId, noteId int, note models.UpdateNoteInput) error {
 	const op = "storage.postgres.UpdateNote"
 
-	err := r.validateId(userId, noteId)
-	if err != nil {
-		return err
-	}
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
 
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
@@ -132,6 +259,10 @@ func (r *NoteRepoPostgres) UpdateNote(userId, noteId int, note models.UpdateNote
 		argId++
 	}
 
+	setValues = append(setValues, fmt.Sprintf("search_language=$%d", argId))
+	args = append(args, r.searchLanguage)
+	argId++
+
 	setValues = append(setValues, "updated_at=now()")
 
 	setQuery := strings.Join(setValues, ", ")
@@ -139,7 +270,7 @@ func (r *NoteRepoPostgres) UpdateNote(userId, noteId int, note models.UpdateNote
 	query := fmt.Sprintf(
 		`UPDATE %s
 		 SET %s
-		 WHERE id = $%d AND user_id = $%d`,
+		 WHERE id = $%d AND user_id = $%d AND deleted_at IS NULL`,
 		storage.NotesTable,
 		setQuery,
 		argId,
@@ -147,45 +278,226 @@ func (r *NoteRepoPostgres) UpdateNote(userId, noteId int, note models.UpdateNote
 	)
 
 	args = append(args, noteId, userId)
-	_, err = r.db.Exec(query, args...)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	if err = validateNoteId(ctx, tx, userId, noteId, false); err != nil {
+		return err
+	}
+
+	if note.Title != nil || note.Content != nil {
+		if err = addRevision(ctx, tx, userId, noteId, note, r.maxRevisions); err != nil {
+			return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+	}
+
+	// the note may have been trashed since it was checked, the revision is
+	// rolled back along with the update then
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	if note.Tags != nil {
+		if err = setNoteTags(ctx, tx, userId, noteId, *note.Tags); err != nil {
+			return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoPostgres) MoveNote(ctx context.Context, userId, noteId int, notebookId *int) error {
+	const op = "storage.postgres.MoveNote"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return err
+	}
+	if err := checkNotebook(ctx, r.db, userId, notebookId); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	query := fmt.Sprintf(
+		"UPDATE %s SET notebook_id = $1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL",
+		storage.NotesTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, notebookId, noteId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
 	return nil
 }
 
-func (r *NoteRepoPostgres) DeleteNote(userId, noteId int) error {
-	const op = "storage.postgres.Delete"
+func (r *NoteRepoPostgres) DeleteNote(ctx context.Context, userId, noteId int) error {
+	const op = "storage.postgres.DeleteNote"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
 
-	err := r.validateId(userId, noteId)
+	err := validateNoteId(ctx, r.db, userId, noteId, false)
 	if err != nil {
 		return err
 	}
 
 	query := fmt.Sprintf(
-		"DELETE FROM %s WHERE id = $1 AND user_id = $2 RETURNING id",
+		"UPDATE %s SET deleted_at = now() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
 		storage.NotesTable,
 	)
-	var deletedID int
-	err = r.db.QueryRow(query, noteId, userId).Scan(&deletedID)
+	if _, err = r.db.ExecContext(ctx, query, noteId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoPostgres) GetTrash(ctx context.Context, userId, limit, offset int) ([]models.NoteDTO, error) {
+	const op = "storage.postgres.GetTrash"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	var notes []models.NoteDTO
+
+	query := fmt.Sprintf(
+		`SELECT n.id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at, n.deleted_at
+		 FROM %s n
+		 WHERE n.user_id = $1 AND n.deleted_at IS NOT NULL
+		 ORDER BY n.deleted_at DESC, n.id DESC
+		 LIMIT $2 OFFSET $3`,
+		noteTagsColumn, storage.NotesTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId, limit, offset)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.NoteDTO
+
+		err = rows.Scan(&n.ID, &n.Title, &n.Content, pq.Array(&n.Tags), &n.NotebookID, &n.CreatedAt, &n.UpdatedAt, &n.DeletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+
+		notes = append(notes, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return notes, nil
+}
+
+func (r *NoteRepoPostgres) RestoreNote(ctx context.Context, userId, noteId int) error {
+	const op = "storage.postgres.RestoreNote"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, true); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = $1 AND user_id = $2", storage.NotesTable)
+	if _, err := r.db.ExecContext(ctx, query, noteId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 
 	return nil
 }
 
-// check access and exist
-func (r *NoteRepoPostgres) validateId(userId, noteId int) error {
-	var ownerID int
-	err := r.db.QueryRow(
-		"SELECT user_id FROM notes WHERE id = $1",
+func (r *NoteRepoPostgres) PurgeNote(ctx context.Context, userId, noteId int) error {
+	const op = "storage.postgres.PurgeNote"
+
+	ctx, end := startQuery(ctx, op, "DELETE", r.timeout)
+	defer end()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, true); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL",
+		storage.NotesTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, noteId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoPostgres) PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int, error) {
+	const op = "storage.postgres.PurgeTrash"
+
+	ctx, end := startQuery(ctx, op, "DELETE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		`DELETE FROM %[1]s WHERE id IN (
+		     SELECT id FROM %[1]s WHERE deleted_at < $1 LIMIT $2
+		 )`,
+		storage.NotesTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return int(n), nil
+}
+
+// validateNoteId checks that the note exists and belongs to the user. trashed
+// tells whether the note is expected in the trash or outside of it, a note on
+// the other side counts as missing.
+func validateNoteId(ctx context.Context, q querier, userId, noteId int, trashed bool) error {
+	var (
+		ownerID   int
+		isTrashed bool
+	)
+	err := q.QueryRowContext(ctx,
+		"SELECT user_id, deleted_at IS NOT NULL FROM notes WHERE id = $1",
 		noteId,
-	).Scan(&ownerID)
+	).Scan(&ownerID, &isTrashed)
 
-	if errors.Is(err, sql.ErrNoRows) {
-		return sql.ErrNoRows
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && isTrashed != trashed) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return queryErr(ctx, err)
 	}
 	if ownerID != userId {
 		return storage.ErrAccessDenied
@@ -193,3 +505,29 @@ func (r *NoteRepoPostgres) validateId(userId, noteId int) error {
 
 	return nil
 }
+
+// setNoteTags replaces the tags of a note, creating the ones the user does not have yet.
+func setNoteTags(ctx context.Context, tx *sql.Tx, userId, noteId int, tags []string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE note_id = $1", storage.NoteTagsTable)
+	if _, err := tx.ExecContext(ctx, query, noteId); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (user_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (user_id, name) DO NOTHING",
+		storage.TagsTable,
+	)
+	if _, err := tx.ExecContext(ctx, query, userId, pq.Array(tags)); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (note_id, tag_id) SELECT $1, id FROM %s WHERE user_id = $2 AND name = ANY($3)",
+		storage.NoteTagsTable, storage.TagsTable,
+	)
+	_, err := tx.ExecContext(ctx, query, noteId, userId, pq.Array(tags))
+	return err
+}
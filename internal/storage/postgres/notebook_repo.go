@@ -0,0 +1,247 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type NotebookRepoPostgres struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewNotebookRepoPostgres(db *sql.DB, timeout time.Duration) *NotebookRepoPostgres {
+	return &NotebookRepoPostgres{db: db, timeout: timeout}
+}
+
+// querier is what *sql.DB and *sql.Tx have in common for single row lookups.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *NotebookRepoPostgres) CreateNotebook(ctx context.Context, nb models.Notebook) (int, error) {
+	const op = "storage.postgres.CreateNotebook"
+
+	ctx, end := startQuery(ctx, op, "INSERT", r.timeout)
+	defer end()
+
+	if err := checkNotebook(ctx, r.db, nb.UserID, nb.ParentID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, parent_id, name) VALUES ($1, $2, $3) RETURNING id",
+		storage.NotebooksTable,
+	)
+	if err := r.db.QueryRowContext(ctx, query, nb.UserID, nb.ParentID, nb.Name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return id, nil
+}
+
+func (r *NotebookRepoPostgres) GetNotebooks(ctx context.Context, userId int) ([]models.Notebook, error) {
+	const op = "storage.postgres.GetNotebooks"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	notebooks := []models.Notebook{}
+
+	query := fmt.Sprintf(
+		"SELECT id, parent_id, name, created_at, updated_at FROM %s WHERE user_id = $1 ORDER BY name, id",
+		storage.NotebooksTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		nb := models.Notebook{UserID: userId}
+		if err = rows.Scan(&nb.ID, &nb.ParentID, &nb.Name, &nb.CreatedAt, &nb.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+		notebooks = append(notebooks, nb)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return notebooks, nil
+}
+
+func (r *NotebookRepoPostgres) GetNotebook(ctx context.Context, userId, notebookId int) (models.Notebook, error) {
+	const op = "storage.postgres.GetNotebook"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	nb := models.Notebook{ID: notebookId}
+
+	query := fmt.Sprintf(
+		"SELECT user_id, parent_id, name, created_at, updated_at FROM %s WHERE id = $1",
+		storage.NotebooksTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, notebookId).Scan(&nb.UserID, &nb.ParentID, &nb.Name, &nb.CreatedAt, &nb.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Notebook{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.Notebook{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if nb.UserID != userId {
+		return models.Notebook{}, fmt.Errorf("%s: %w", op, storage.ErrAccessDenied)
+	}
+
+	return nb, nil
+}
+
+func (r *NotebookRepoPostgres) RenameNotebook(ctx context.Context, userId, notebookId int, name string) error {
+	const op = "storage.postgres.RenameNotebook"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	if err := validateNotebookId(ctx, r.db, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET name = $1, updated_at = now() WHERE id = $2 AND user_id = $3",
+		storage.NotebooksTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, name, notebookId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+// MoveNotebook refuses to move a notebook below itself, which would detach
+// the whole branch from the tree.
+func (r *NotebookRepoPostgres) MoveNotebook(ctx context.Context, userId, notebookId int, parentId *int) error {
+	const op = "storage.postgres.MoveNotebook"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	// two concurrent moves could each pass the cycle check and still form a
+	// cycle together, so a user's moves take turns on the user's row
+	var locked int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 FOR UPDATE", storage.UsersTable)
+	if err = tx.QueryRowContext(ctx, query, userId).Scan(&locked); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = validateNotebookId(ctx, tx, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if parentId != nil {
+		if err = checkNotebook(ctx, tx, userId, parentId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		var cycle bool
+		query = fmt.Sprintf(
+			`WITH RECURSIVE ancestors (id, parent_id) AS (
+			     SELECT id, parent_id FROM %[1]s WHERE id = $1
+			     UNION
+			     SELECT nb.id, nb.parent_id FROM %[1]s nb JOIN ancestors a ON nb.id = a.parent_id
+			 )
+			 SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`,
+			storage.NotebooksTable,
+		)
+		if err = tx.QueryRowContext(ctx, query, *parentId, notebookId).Scan(&cycle); err != nil {
+			return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+		if cycle {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotebookCycle)
+		}
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET parent_id = $1, updated_at = now() WHERE id = $2", storage.NotebooksTable)
+	if _, err = tx.ExecContext(ctx, query, parentId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NotebookRepoPostgres) DeleteNotebook(ctx context.Context, userId, notebookId int) error {
+	const op = "storage.postgres.DeleteNotebook"
+
+	ctx, end := startQuery(ctx, op, "DELETE", r.timeout)
+	defer end()
+
+	if err := validateNotebookId(ctx, r.db, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// nested notebooks go with it and their notes are unfiled by the foreign keys
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2", storage.NotebooksTable)
+	if _, err := r.db.ExecContext(ctx, query, notebookId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+// validateNotebookId checks that the notebook exists and belongs to the user.
+func validateNotebookId(ctx context.Context, q querier, userId, notebookId int) error {
+	var ownerID int
+
+	query := fmt.Sprintf("SELECT user_id FROM %s WHERE id = $1", storage.NotebooksTable)
+	err := q.QueryRowContext(ctx, query, notebookId).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return queryErr(ctx, err)
+	}
+	if ownerID != userId {
+		return storage.ErrAccessDenied
+	}
+
+	return nil
+}
+
+// checkNotebook validates a notebook referenced from a request body, a
+// missing notebook and someone else's look the same to the caller.
+func checkNotebook(ctx context.Context, q querier, userId int, notebookId *int) error {
+	if notebookId == nil {
+		return nil
+	}
+
+	var exists bool
+
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND user_id = $2)", storage.NotebooksTable)
+	if err := q.QueryRowContext(ctx, query, *notebookId, userId).Scan(&exists); err != nil {
+		return queryErr(ctx, err)
+	}
+	if !exists {
+		return storage.ErrInvalidNotebook
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type PasswordResetRepoPostgres struct {
+	db      *sql.DB
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func NewPasswordResetRepoPostgres(db *sql.DB, ttl time.Duration, timeout time.Duration) *PasswordResetRepoPostgres {
+	return &PasswordResetRepoPostgres{db: db, ttl: ttl, timeout: timeout}
+}
+
+// CreateResetToken issues a reset token and invalidates the ones requested before it.
+func (r *PasswordResetRepoPostgres) CreateResetToken(ctx context.Context, userId int) (string, error) {
+	const op = "storage.postgres.CreateResetToken"
+
+	ctx, end := startQuery(ctx, op, "INSERT", r.timeout)
+	defer end()
+
+	token, err := storage.RandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET used_at = now() WHERE user_id = $1 AND used_at IS NULL",
+		storage.PasswordResetTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		storage.PasswordResetTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, userId, storage.HashToken(token), time.Now().Add(r.ttl)); err != nil {
+		return "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return token, nil
+}
+
+// ConsumeResetToken marks the token as used and returns the user it was issued to.
+func (r *PasswordResetRepoPostgres) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	const op = "storage.postgres.ConsumeResetToken"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	var userId int
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET used_at = now()
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		 RETURNING user_id`,
+		storage.PasswordResetTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, storage.HashToken(token)).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github/yusupovkuzs/GoNotesApp/internal/storage/postgres")
+
+// startQuery opens the span of a repository call and bounds the call by
+// timeout. operation is the main SQL statement, empty when the call only
+// delegates to other repository methods.
+func startQuery(ctx context.Context, op, operation string, timeout time.Duration) (context.Context, func()) {
+	attrs := []attribute.KeyValue{attribute.String("db.system.name", "postgresql")}
+	if operation != "" {
+		attrs = append(attrs, attribute.String("db.operation.name", operation))
+	}
+
+	ctx, span := tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, func() {
+		cancel()
+		span.End()
+	}
+}
+
+// queryErr marks the call's span as failed and prefers the context's error
+// over the driver's, so a cancelled or timed out query can be told apart
+// from a failed one.
+func queryErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	return err
+}
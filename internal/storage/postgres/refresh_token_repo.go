@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RefreshTokenRepoPostgres struct {
+	db      *sql.DB
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func NewRefreshTokenRepoPostgres(db *sql.DB, ttl time.Duration, timeout time.Duration) *RefreshTokenRepoPostgres {
+	return &RefreshTokenRepoPostgres{db: db, ttl: ttl, timeout: timeout}
+}
+
+// CreateRefreshToken starts a new token family for the user and returns its first token.
+func (r *RefreshTokenRepoPostgres) CreateRefreshToken(ctx context.Context, userId int) (string, error) {
+	const op = "storage.postgres.CreateRefreshToken"
+
+	ctx, end := startQuery(ctx, op, "INSERT", r.timeout)
+	defer end()
+
+	familyId, err := storage.RandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := r.insertRefreshToken(ctx, r.db, userId, familyId)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same family.
+// Presenting a token that was already rotated revokes the whole family.
+func (r *RefreshTokenRepoPostgres) RotateRefreshToken(ctx context.Context, token string) (int, string, error) {
+	const op = "storage.postgres.RotateRefreshToken"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	var (
+		id, userId int
+		familyId   string
+		expiresAt  time.Time
+		usedAt     sql.NullTime
+		revokedAt  sql.NullTime
+	)
+
+	query := fmt.Sprintf(
+		`SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		 FROM %s
+		 WHERE token_hash = $1
+		 FOR UPDATE`,
+		storage.RefreshTokensTable,
+	)
+	err = tx.QueryRowContext(ctx, query, storage.HashToken(token)).Scan(&id, &userId, &familyId, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", storage.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if usedAt.Valid {
+		if err = revokeFamily(ctx, tx, familyId); err != nil {
+			return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+		if err = tx.Commit(); err != nil {
+			return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+		return 0, "", storage.ErrRefreshTokenReused
+	}
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		return 0, "", storage.ErrInvalidRefreshToken
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET used_at = now() WHERE id = $1", storage.RefreshTokensTable)
+	if _, err = tx.ExecContext(ctx, query, id); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	newToken, err := r.insertRefreshToken(ctx, tx, userId, familyId)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return userId, newToken, nil
+}
+
+// RevokeRefreshToken revokes the family of a refresh token owned by the user.
+func (r *RefreshTokenRepoPostgres) RevokeRefreshToken(ctx context.Context, userId int, token string) error {
+	const op = "storage.postgres.RevokeRefreshToken"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		`UPDATE %[1]s SET revoked_at = now()
+		 WHERE revoked_at IS NULL AND family_id = (
+		     SELECT family_id FROM %[1]s WHERE token_hash = $1 AND user_id = $2
+		 )`,
+		storage.RefreshTokensTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, storage.HashToken(token), userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrInvalidRefreshToken
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepoPostgres) RevokeUserRefreshTokens(ctx context.Context, userId int) error {
+	const op = "storage.postgres.RevokeUserRefreshTokens"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL",
+		storage.RefreshTokensTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *RefreshTokenRepoPostgres) insertRefreshToken(ctx context.Context, q queryRower, userId int, familyId string) (string, error) {
+	token, err := storage.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		storage.RefreshTokensTable,
+	)
+	var id int
+	err = q.QueryRowContext(ctx, query, userId, familyId, storage.HashToken(token), time.Now().Add(r.ttl)).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func revokeFamily(ctx context.Context, tx *sql.Tx, familyId string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL",
+		storage.RefreshTokensTable,
+	)
+	_, err := tx.ExecContext(ctx, query, familyId)
+	return err
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RevisionRepoPostgres struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRevisionRepoPostgres(db *sql.DB, timeout time.Duration) *RevisionRepoPostgres {
+	return &RevisionRepoPostgres{db: db, timeout: timeout}
+}
+
+func (r *RevisionRepoPostgres) GetRevisions(ctx context.Context, userId, noteId, limit, offset int) ([]models.NoteRevision, error) {
+	const op = "storage.postgres.GetRevisions"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return nil, err
+	}
+
+	revisions := []models.NoteRevision{}
+
+	query := fmt.Sprintf(
+		`SELECT id, note_id, author_id, title, content, created_at
+		 FROM %s
+		 WHERE note_id = $1
+		 ORDER BY id DESC
+		 LIMIT $2 OFFSET $3`,
+		storage.NoteRevisionsTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, noteId, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev models.NoteRevision
+
+		err = rows.Scan(&rev.ID, &rev.NoteID, &rev.AuthorID, &rev.Title, &rev.Content, &rev.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return revisions, nil
+}
+
+func (r *RevisionRepoPostgres) GetRevision(ctx context.Context, userId, noteId, revisionId int) (models.NoteRevision, error) {
+	const op = "storage.postgres.GetRevision"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return models.NoteRevision{}, err
+	}
+
+	var rev models.NoteRevision
+
+	query := fmt.Sprintf(
+		"SELECT id, note_id, author_id, title, content, created_at FROM %s WHERE id = $1 AND note_id = $2",
+		storage.NoteRevisionsTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, revisionId, noteId).
+		Scan(&rev.ID, &rev.NoteID, &rev.AuthorID, &rev.Title, &rev.Content, &rev.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NoteRevision{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.NoteRevision{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return rev, nil
+}
+
+// addRevision keeps the note's current title and content before update
+// changes them, nothing is kept if neither would change. Beyond maxRevisions
+// the oldest revisions of the note are dropped.
+func addRevision(ctx context.Context, tx *sql.Tx, authorId, noteId int, update models.UpdateNoteInput, maxRevisions int) error {
+	// locking the note keeps concurrent updates from recording the same state twice
+	query := fmt.Sprintf(
+		`INSERT INTO %s (note_id, author_id, title, content)
+		 SELECT id, $2, title, content FROM %s
+		 WHERE id = $1 AND deleted_at IS NULL
+		   AND (title <> COALESCE($3, title) OR content <> COALESCE($4, content))
+		 FOR UPDATE`,
+		storage.NoteRevisionsTable, storage.NotesTable,
+	)
+	res, err := tx.ExecContext(ctx, query, noteId, authorId, update.Title, update.Content)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 || maxRevisions <= 0 {
+		return nil
+	}
+
+	query = fmt.Sprintf(
+		`DELETE FROM %[1]s WHERE note_id = $1 AND id NOT IN (
+		     SELECT id FROM %[1]s WHERE note_id = $1 ORDER BY id DESC LIMIT $2
+		 )`,
+		storage.NoteRevisionsTable,
+	)
+	_, err = tx.ExecContext(ctx, query, noteId, maxRevisions)
+	return err
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RevocationRepoPostgres struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRevocationRepoPostgres(db *sql.DB, timeout time.Duration) *RevocationRepoPostgres {
+	return &RevocationRepoPostgres{db: db, timeout: timeout}
+}
+
+func (r *RevocationRepoPostgres) RevokeToken(ctx context.Context, jti string, userId int, expiresAt time.Time) error {
+	const op = "storage.postgres.RevokeToken"
+
+	ctx, end := startQuery(ctx, op, "INSERT", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (jti, user_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING",
+		storage.RevokedTokensTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, jti, userId, expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	// expired tokens are rejected anyway, no need to remember them
+	query = fmt.Sprintf("DELETE FROM %s WHERE expires_at < now()", storage.RevokedTokensTable)
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *RevocationRepoPostgres) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	const op = "storage.postgres.IsTokenRevoked"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	var exists bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE jti = $1)", storage.RevokedTokensTable)
+	if err := r.db.QueryRowContext(ctx, query, jti).Scan(&exists); err != nil {
+		return false, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return exists, nil
+}
+
+// RevokeAllTokens invalidates every token issued to the user before now.
+func (r *RevocationRepoPostgres) RevokeAllTokens(ctx context.Context, userId int) (time.Time, error) {
+	const op = "storage.postgres.RevokeAllTokens"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	// iat has a one second resolution, tokens issued later within the same second stay valid
+	before := time.Now().Truncate(time.Second)
+
+	query := fmt.Sprintf("UPDATE %s SET tokens_revoked_before = $1 WHERE id = $2", storage.UsersTable)
+	if _, err := r.db.ExecContext(ctx, query, before, userId); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return before, nil
+}
+
+// TokensRevokedBefore returns the user's revocation watermark, zero if there is none.
+func (r *RevocationRepoPostgres) TokensRevokedBefore(ctx context.Context, userId int) (time.Time, error) {
+	const op = "storage.postgres.TokensRevokedBefore"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	var before sql.NullTime
+	query := fmt.Sprintf("SELECT tokens_revoked_before FROM %s WHERE id = $1", storage.UsersTable)
+	err := r.db.QueryRowContext(ctx, query, userId).Scan(&before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return before.Time, nil
+}
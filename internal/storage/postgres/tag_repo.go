@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TagRepoPostgres struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewTagRepoPostgres(db *sql.DB, timeout time.Duration) *TagRepoPostgres {
+	return &TagRepoPostgres{db: db, timeout: timeout}
+}
+
+// ListTags returns the user's tags by name, with the number of notes carrying
+// each. Trashed notes are not counted.
+func (r *TagRepoPostgres) ListTags(ctx context.Context, userId int) ([]models.Tag, error) {
+	const op = "storage.postgres.ListTags"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	tags := []models.Tag{}
+
+	query := fmt.Sprintf(
+		`SELECT t.name, count(n.id)
+		 FROM %s t
+		 LEFT JOIN %s nt ON nt.tag_id = t.id
+		 LEFT JOIN %s n ON n.id = nt.note_id AND n.deleted_at IS NULL
+		 WHERE t.user_id = $1
+		 GROUP BY t.id
+		 ORDER BY t.name`,
+		storage.TagsTable, storage.NoteTagsTable, storage.NotesTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Tag
+		if err = rows.Scan(&t.Name, &t.NoteCount); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+		}
+		tags = append(tags, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return tags, nil
+}
+
+// RenameTag fails with storage.ErrTagExists if newName is taken, MergeTag joins two tags.
+func (r *TagRepoPostgres) RenameTag(ctx context.Context, userId int, name, newName string) error {
+	const op = "storage.postgres.RenameTag"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf("UPDATE %s SET name = $1 WHERE user_id = $2 AND name = $3", storage.TagsTable)
+	res, err := r.db.ExecContext(ctx, query, newName, userId, name)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrTagExists)
+		}
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// MergeTag moves the notes tagged name over to into, creating it if needed,
+// and deletes name.
+func (r *TagRepoPostgres) MergeTag(ctx context.Context, userId int, name, into string) error {
+	const op = "storage.postgres.MergeTag"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	var sourceId, targetId int
+
+	query := fmt.Sprintf("SELECT id FROM %s WHERE user_id = $1 AND name = $2", storage.TagsTable)
+	err = tx.QueryRowContext(ctx, query, userId, name).Scan(&sourceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	// the no-op update makes RETURNING yield the id of an existing tag too
+	query = fmt.Sprintf(
+		`INSERT INTO %s (user_id, name) VALUES ($1, $2)
+		 ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id`,
+		storage.TagsTable,
+	)
+	if err = tx.QueryRowContext(ctx, query, userId, into).Scan(&targetId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if targetId == sourceId {
+		return nil
+	}
+
+	query = fmt.Sprintf(
+		`INSERT INTO %[1]s (note_id, tag_id)
+		 SELECT note_id, $1 FROM %[1]s WHERE tag_id = $2
+		 ON CONFLICT DO NOTHING`,
+		storage.NoteTagsTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, targetId, sourceId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE id = $1", storage.TagsTable)
+	if _, err = tx.ExecContext(ctx, query, sourceId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
+}
+
+// DeleteTag removes the tag from the user's notes, the notes themselves stay.
+func (r *TagRepoPostgres) DeleteTag(ctx context.Context, userId int, name string) error {
+	const op = "storage.postgres.DeleteTag"
+
+	ctx, end := startQuery(ctx, op, "DELETE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND name = $2", storage.TagsTable)
+	res, err := r.db.ExecContext(ctx, query, userId, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
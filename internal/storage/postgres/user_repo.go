@@ -1,123 +1,229 @@
 package postgres
 
 import (
-	"crypto/sha1"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github/yusupovkuzs/GoNotesApp/internal/models"
 	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/password"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/lib/pq"
 )
 
-const (
-	salt      = "fjewohf7a434gfuoebf9w4"
-	signInKey = "oewfuhy6t328yif32g"
-	tokenTTL  = time.Hour * 12
-)
-
-type UserRepository interface {
-	CreateUser(user models.CreateUserRequest) (int, error)
-	GetUser(username, password string) (models.User, error)
-}
-
 type UserRepoPostgres struct {
-	db *sql.DB
+	db      *sql.DB
+	hasher  *password.Hasher
+	timeout time.Duration
 }
 
-func NewUserRepoPostgres(db *sql.DB) *UserRepoPostgres {
-	return &UserRepoPostgres{db: db}
+func NewUserRepoPostgres(db *sql.DB, hasher *password.Hasher, timeout time.Duration) *UserRepoPostgres {
+	return &UserRepoPostgres{db: db, hasher: hasher, timeout: timeout}
 }
 
-func (r *UserRepoPostgres) CreateUser(u models.User) (int, error) {
+func (r *UserRepoPostgres) CreateUser(ctx context.Context, u models.User) (int, error) {
 	const op = "storage.postgres.CreateUser"
 
+	ctx, end := startQuery(ctx, op, "INSERT", r.timeout)
+	defer end()
+
 	var id int
-	u.Password = generatePasswordHash(u.Password)
+	hash, err := r.hasher.Hash(u.Password)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 
 	query := fmt.Sprintf(
-		"INSERT INTO %s (username, password_hash) VALUES ($1, $2) RETURNING id",
+		"INSERT INTO %s (username, password_hash, email) VALUES ($1, $2, NULLIF($3, '')) RETURNING id",
 		storage.UsersTable,
 	)
-	if err := r.db.QueryRow(query, u.Username, u.Password).Scan(&id); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, u.Username, hash, u.Email).Scan(&id); err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) {
 			switch pqErr.Code {
 			case "23505":
+				if pqErr.Constraint == "users_email_key" {
+					return 0, storage.ErrEmailTaken
+				}
 				return 0, storage.ErrUsernameTaken
 			}
 		}
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 
 	return id, nil
 }
 
-func (r *UserRepoPostgres) GetUser(username, password string) (models.User, error) {
+const userColumns = "id, username, password_hash, totp_enabled, email, email_verified_at, created_at"
+
+func scanUser(row *sql.Row) (models.User, error) {
+	var (
+		user       models.User
+		email      sql.NullString
+		verifiedAt sql.NullTime
+	)
+
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.TOTPEnabled, &email, &verifiedAt, &user.CreatedAt)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.Email = email.String
+	user.EmailVerified = verifiedAt.Valid
+	return user, nil
+}
+
+func (r *UserRepoPostgres) GetUser(ctx context.Context, username string) (models.User, error) {
 	const op = "storage.postgres.GetUser"
 
-	var user models.User
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE username = $1", userColumns, storage.UsersTable)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return user, nil
+}
+
+func (r *UserRepoPostgres) GetUserByID(ctx context.Context, userId int) (models.User, error) {
+	const op = "storage.postgres.GetUserByID"
+
+	ctx, end := startQuery(ctx, op, "SELECT", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", userColumns, storage.UsersTable)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return user, nil
+}
+
+// MarkEmailVerified verifies the user's email, provided it hasn't changed since the link was sent.
+func (r *UserRepoPostgres) MarkEmailVerified(ctx context.Context, userId int, email string) error {
+	const op = "storage.postgres.MarkEmailVerified"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
 
 	query := fmt.Sprintf(
-		"SELECT id, created_at FROM %s WHERE username = $1 AND password_hash = $2",
+		`UPDATE %s SET email_verified_at = COALESCE(email_verified_at, now())
+		 WHERE id = $1 AND lower(email) = lower($2)`,
 		storage.UsersTable,
 	)
-	if err := r.db.QueryRow(query, username, password).Scan(&user.ID, &user.CreatedAt); err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	res, err := r.db.ExecContext(ctx, query, userId, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
 	}
 
-	user.Username = username
-	user.Password = password
-	return user, nil
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrInvalidVerificationToken
+	}
+
+	return nil
 }
 
-func generatePasswordHash(password string) string {
-	hash := sha1.New()
-	hash.Write([]byte(password))
+// VerifyPassword re-confirms the password of an already authenticated user.
+func (r *UserRepoPostgres) VerifyPassword(ctx context.Context, userId int, password string) error {
+	const op = "storage.postgres.VerifyPassword"
+
+	ctx, end := startQuery(ctx, op, "", r.timeout)
+	defer end()
+
+	user, err := r.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return storage.ErrInvalidCredentials
+	}
 
-	return fmt.Sprintf("%x", hash.Sum([]byte(salt)))
+	return nil
 }
 
-type tokenClaims struct {
-	jwt.StandardClaims
-	UserId int `json:"user_id"`
+func (r *UserRepoPostgres) UpdatePasswordHash(ctx context.Context, userId int, hash string) error {
+	const op = "storage.postgres.UpdatePasswordHash"
+
+	ctx, end := startQuery(ctx, op, "UPDATE", r.timeout)
+	defer end()
+
+	query := fmt.Sprintf("UPDATE %s SET password_hash = $1 WHERE id = $2", storage.UsersTable)
+	if _, err := r.db.ExecContext(ctx, query, hash, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, queryErr(ctx, err))
+	}
+
+	return nil
 }
 
-func (r *UserRepoPostgres) GenerateToken(username, password string) (string, error) {
-	user, err := r.GetUser(username, generatePasswordHash(password))
+// UpdatePassword replaces the user's password with a hash of the new one.
+func (r *UserRepoPostgres) UpdatePassword(ctx context.Context, userId int, password string) error {
+	const op = "storage.postgres.UpdatePassword"
+
+	ctx, end := startQuery(ctx, op, "", r.timeout)
+	defer end()
+
+	hash, err := r.hasher.Hash(password)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(tokenTTL).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-		user.ID,
-	})
 
-	return token.SignedString([]byte(signInKey))
+	return r.UpdatePasswordHash(ctx, userId, hash)
 }
 
-func (r *UserRepoPostgres) ParseToken(accessToken string) (int, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
+// Authenticate checks the user's password and upgrades its hash when it was
+// produced by an outdated scheme.
+func (r *UserRepoPostgres) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	const op = "storage.postgres.Authenticate"
 
-		return []byte(signInKey), nil
-	})
+	ctx, end := startQuery(ctx, op, "", r.timeout)
+	defer end()
+
+	user, err := r.GetUser(ctx, username)
+	if errors.Is(err, storage.ErrNotFound) {
+		r.hasher.VerifyDummy(password)
+		return models.User{}, storage.ErrInvalidCredentials
+	}
 	if err != nil {
-		return 0, err
+		return models.User{}, err
 	}
 
-	claims, ok := token.Claims.(*tokenClaims)
+	ok, err := r.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return models.User{}, storage.ErrInvalidCredentials
+	}
+
+	if r.hasher.NeedsRehash(user.PasswordHash) {
+		// best effort, the upgrade is retried on the next successful login
+		if hash, err := r.hasher.Hash(password); err == nil {
+			if err = r.UpdatePasswordHash(ctx, user.ID, hash); err == nil {
+				user.PasswordHash = hash
+			}
+		}
 	}
 
-	return claims.UserId, nil
+	return user, nil
 }
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/search"
+	"time"
+)
+
+type NoteRepository interface {
+	CreateNote(ctx context.Context, note models.Note) (int, error)
+	GetAllNotes(ctx context.Context, userId int, filter NoteFilter) ([]models.NoteDTO, error)
+	// SearchNotes returns the notes matching query, best matches first.
+	SearchNotes(ctx context.Context, userId int, query search.Query, limit, offset int) ([]models.NoteSearchResult, error)
+	GetNote(ctx context.Context, userId, noteId int) (models.Note, error)
+	// UpdateNote keeps the title and content it changes as a revision authored
+	// by userId, dropping the oldest revisions beyond the configured maximum.
+	UpdateNote(ctx context.Context, userId, noteId int, note models.UpdateNoteInput) error
+	// MoveNote files the note into notebookId, nil takes it out of its notebook.
+	MoveNote(ctx context.Context, userId, noteId int, notebookId *int) error
+	// DeleteNote moves the note to the trash, the other methods treat trashed
+	// notes as missing until they are restored.
+	DeleteNote(ctx context.Context, userId, noteId int) error
+	// GetTrash lists the user's trashed notes, most recently deleted first.
+	GetTrash(ctx context.Context, userId, limit, offset int) ([]models.NoteDTO, error)
+	RestoreNote(ctx context.Context, userId, noteId int) error
+	// PurgeNote permanently deletes a note from the trash.
+	PurgeNote(ctx context.Context, userId, noteId int) error
+	// PurgeTrash permanently deletes up to limit notes of any user trashed
+	// before deletedBefore and returns how many it deleted.
+	PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int, error)
+}
+
+// NoteFilter selects and pages the notes GetAllNotes returns.
+type NoteFilter struct {
+	Limit  int
+	Offset int
+	// Sort orders by creation time, "asc" or "desc".
+	Sort string
+	// Tags keeps the notes carrying any of them, or all of them with MatchAllTags.
+	Tags         []string
+	MatchAllTags bool
+	// NotebookID keeps the notes filed in that notebook, with Descendants also
+	// those in the notebooks nested below it. Zero keeps notes of any notebook.
+	NotebookID  int
+	Descendants bool
+}
+
+// TagRepository manages a user's tags, tags are attached to notes through NoteRepository.
+type TagRepository interface {
+	ListTags(ctx context.Context, userId int) ([]models.Tag, error)
+	RenameTag(ctx context.Context, userId int, name, newName string) error
+	MergeTag(ctx context.Context, userId int, name, into string) error
+	DeleteTag(ctx context.Context, userId int, name string) error
+}
+
+// NotebookRepository manages a user's notebook tree. Referencing a notebook the
+// user does not own fails with ErrInvalidNotebook, moving one below itself
+// with ErrNotebookCycle.
+type NotebookRepository interface {
+	CreateNotebook(ctx context.Context, notebook models.Notebook) (int, error)
+	GetNotebooks(ctx context.Context, userId int) ([]models.Notebook, error)
+	GetNotebook(ctx context.Context, userId, notebookId int) (models.Notebook, error)
+	RenameNotebook(ctx context.Context, userId, notebookId int, name string) error
+	MoveNotebook(ctx context.Context, userId, notebookId int, parentId *int) error
+	// DeleteNotebook deletes the notebook with everything nested below it, the
+	// notes filed there are kept outside any notebook.
+	DeleteNotebook(ctx context.Context, userId, notebookId int) error
+}
+
+// RevisionRepository reads the revisions UpdateNote records. The revisions of
+// a trashed note are hidden along with it.
+type RevisionRepository interface {
+	// GetRevisions lists the note's revisions, newest first.
+	GetRevisions(ctx context.Context, userId, noteId, limit, offset int) ([]models.NoteRevision, error)
+	GetRevision(ctx context.Context, userId, noteId, revisionId int) (models.NoteRevision, error)
+}
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, user models.User) (int, error)
+	GetUser(ctx context.Context, username string) (models.User, error)
+	GetUserByID(ctx context.Context, userId int) (models.User, error)
+	MarkEmailVerified(ctx context.Context, userId int, email string) error
+	VerifyPassword(ctx context.Context, userId int, password string) error
+	UpdatePassword(ctx context.Context, userId int, password string) error
+	Authenticate(ctx context.Context, username, password string) (models.User, error)
+}
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, userId int) (string, error)
+	RotateRefreshToken(ctx context.Context, token string) (int, string, error)
+	RevokeRefreshToken(ctx context.Context, userId int, token string) error
+	RevokeUserRefreshTokens(ctx context.Context, userId int) error
+}
+
+type MFARepository interface {
+	GetTOTP(ctx context.Context, userId int) (models.TOTP, error)
+	SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error
+	EnableTOTP(ctx context.Context, userId int, step int64, recoveryCodes []string) error
+	DisableTOTP(ctx context.Context, userId int) error
+	UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userId int, code string) (bool, error)
+}
+
+type PasswordResetRepository interface {
+	CreateResetToken(ctx context.Context, userId int) (string, error)
+	ConsumeResetToken(ctx context.Context, token string) (int, error)
+}
+
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, jti string, userId int, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeAllTokens(ctx context.Context, userId int) (time.Time, error)
+	TokensRevokedBefore(ctx context.Context, userId int) (time.Time, error)
+}
+
+// Repositories bundles the repositories of one storage backend.
+type Repositories struct {
+	Notes          NoteRepository
+	Tags           TagRepository
+	Notebooks      NotebookRepository
+	Revisions      RevisionRepository
+	Users          UserRepository
+	RefreshTokens  RefreshTokenRepository
+	MFA            MFARepository
+	PasswordResets PasswordResetRepository
+	Revocations    RevocationRepository
+}
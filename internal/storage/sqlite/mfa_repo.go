@@ -0,0 +1,208 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type MFARepoSQLite struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewMFARepoSQLite(db *sql.DB, timeout time.Duration) *MFARepoSQLite {
+	return &MFARepoSQLite{db: db, timeout: timeout}
+}
+
+func (r *MFARepoSQLite) GetTOTP(ctx context.Context, userId int) (models.TOTP, error) {
+	const op = "storage.sqlite.GetTOTP"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var (
+		totp   models.TOTP
+		secret sql.NullString
+	)
+
+	query := fmt.Sprintf(
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM %s WHERE id = ?",
+		storage.UsersTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, userId).Scan(&secret, &totp.Enabled, &totp.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	totp.Secret = secret.String
+	return totp, nil
+}
+
+// SetPendingTOTPSecret stores a secret that only takes effect once EnableTOTP confirms it.
+func (r *MFARepoSQLite) SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error {
+	const op = "storage.sqlite.SetPendingTOTPSecret"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_secret = ? WHERE id = ? AND NOT totp_enabled",
+		storage.UsersTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, secret, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on 2FA and replaces the user's recovery codes.
+func (r *MFARepoSQLite) EnableTOTP(ctx context.Context, userId int, step int64, recoveryCodes []string) error {
+	const op = "storage.sqlite.EnableTOTP"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET totp_enabled = true, totp_last_step = ?
+		 WHERE id = ? AND totp_secret IS NOT NULL AND NOT totp_enabled`,
+		storage.UsersTable,
+	)
+	res, err := tx.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrMFAAlreadyEnabled
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", storage.RecoveryCodesTable)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s (user_id, code_hash, created_at) VALUES (?, ?, ?)", storage.RecoveryCodesTable)
+	for _, code := range recoveryCodes {
+		if _, err = tx.ExecContext(ctx, query, userId, storage.HashToken(code), now()); err != nil {
+			return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *MFARepoSQLite) DisableTOTP(ctx context.Context, userId int) error {
+	const op = "storage.sqlite.DisableTOTP"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0 WHERE id = ?",
+		storage.UsersTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", storage.RecoveryCodesTable)
+	if _, err = tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+// UseTOTPStep records a successfully validated time step and reports false
+// if it, or a later one, was already used.
+func (r *MFARepoSQLite) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	const op = "storage.sqlite.UseTOTPStep"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		storage.UsersTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, step, userId, step)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return n == 1, nil
+}
+
+// UseRecoveryCode consumes a recovery code and reports false if it is unknown or already used.
+func (r *MFARepoSQLite) UseRecoveryCode(ctx context.Context, userId int, code string) (bool, error) {
+	const op = "storage.sqlite.UseRecoveryCode"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		`UPDATE %[1]s SET used_at = ?
+		 WHERE id = (
+		     SELECT id FROM %[1]s WHERE user_id = ? AND code_hash = ? AND used_at IS NULL LIMIT 1
+		 )`,
+		storage.RecoveryCodesTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, now(), userId, storage.HashToken(code))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return n == 1, nil
+}
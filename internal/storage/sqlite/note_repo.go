@@ -0,0 +1,527 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/search"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"strings"
+	"time"
+)
+
+// noteTagsColumn selects the comma separated, sorted tag names of the note aliased n.
+var noteTagsColumn = fmt.Sprintf(
+	`(SELECT group_concat(name, ',') FROM (
+	     SELECT t.name FROM %s nt JOIN %s t ON t.id = nt.tag_id WHERE nt.note_id = n.id ORDER BY t.name
+	 ))`,
+	storage.NoteTagsTable, storage.TagsTable,
+)
+
+type NoteRepoSQLite struct {
+	db *sql.DB
+	// maxRevisions bounds the revisions kept per note, zero keeps them all.
+	maxRevisions int
+	timeout      time.Duration
+}
+
+func NewNoteRepoSQLite(db *sql.DB, maxRevisions int, timeout time.Duration) *NoteRepoSQLite {
+	return &NoteRepoSQLite{db: db, maxRevisions: maxRevisions, timeout: timeout}
+}
+
+func (r *NoteRepoSQLite) CreateNote(ctx context.Context, n models.Note) (int, error) {
+	const op = "storage.sqlite.CreateNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	if err = checkNotebook(ctx, tx, n.UserID, n.NotebookID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, title, content, notebook_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		storage.NotesTable,
+	)
+	created := now()
+	row := tx.QueryRowContext(ctx, query, n.UserID, n.Title, n.Content, n.NotebookID, created, created)
+	if err := row.Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = setNoteTags(ctx, tx, n.UserID, id, n.Tags); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return id, nil
+}
+
+func (r *NoteRepoSQLite) GetAllNotes(ctx context.Context, userId int, filter storage.NoteFilter) ([]models.NoteDTO, error) {
+	const op = "storage.sqlite.GetAllNotes"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var notes []models.NoteDTO
+
+	sort := filter.Sort
+	if sort != "desc" {
+		sort = "asc"
+	}
+
+	conditions := []string{"n.user_id = ?", "n.deleted_at IS NULL"}
+	args := []interface{}{userId}
+
+	if len(filter.Tags) > 0 {
+		tagged := fmt.Sprintf(
+			`FROM %s nt JOIN %s t ON t.id = nt.tag_id
+			 WHERE nt.note_id = n.id AND t.name IN (%s)`,
+			storage.NoteTagsTable, storage.TagsTable, placeholders(len(filter.Tags)),
+		)
+		for _, t := range filter.Tags {
+			args = append(args, t)
+		}
+		if filter.MatchAllTags {
+			conditions = append(conditions, fmt.Sprintf("(SELECT count(*) %s) = ?", tagged))
+			args = append(args, len(filter.Tags))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 %s)", tagged))
+		}
+	}
+
+	if filter.NotebookID != 0 {
+		args = append(args, filter.NotebookID)
+		if filter.Descendants {
+			conditions = append(conditions, fmt.Sprintf(
+				`n.notebook_id IN (
+				     WITH RECURSIVE tree (id) AS (
+				         SELECT id FROM %[1]s WHERE id = ?
+				         UNION
+				         SELECT nb.id FROM %[1]s nb JOIN tree ON nb.parent_id = tree.id
+				     )
+				     SELECT id FROM tree
+				 )`,
+				storage.NotebooksTable,
+			))
+		} else {
+			conditions = append(conditions, "n.notebook_id = ?")
+		}
+	}
+
+	query := fmt.Sprintf(
+		`SELECT n.id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at
+		 FROM %s n
+		 WHERE %s
+		 ORDER BY n.created_at %[4]s, n.id %[4]s
+		 LIMIT ? OFFSET ?`,
+		noteTagsColumn, storage.NotesTable, strings.Join(conditions, " AND "), sort,
+	)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			n    models.NoteDTO
+			tags sql.NullString
+		)
+
+		err = rows.Scan(&n.ID, &n.Title, &n.Content, &tags, &n.NotebookID, &n.CreatedAt, &n.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		n.Tags = splitTags(tags)
+
+		notes = append(notes, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return notes, nil
+}
+
+// SearchNotes has no full-text index to use, it scans the user's notes and
+// matches them with the search package.
+func (r *NoteRepoSQLite) SearchNotes(ctx context.Context, userId int, q search.Query, limit, offset int) ([]models.NoteSearchResult, error) {
+	const op = "storage.sqlite.SearchNotes"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var results []models.NoteSearchResult
+
+	query := fmt.Sprintf(
+		"SELECT n.id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at FROM %s n WHERE n.user_id = ? AND n.deleted_at IS NULL",
+		noteTagsColumn, storage.NotesTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			n    models.NoteDTO
+			tags sql.NullString
+		)
+
+		err = rows.Scan(&n.ID, &n.Title, &n.Content, &tags, &n.NotebookID, &n.CreatedAt, &n.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+
+		hit, ok := q.Match(n.Title, n.Content)
+		if !ok {
+			continue
+		}
+		n.Tags = splitTags(tags)
+		results = append(results, models.NoteSearchResult{NoteDTO: n, Rank: hit.Rank, Snippet: hit.Snippet})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return search.Page(results, limit, offset), nil
+}
+
+func (r *NoteRepoSQLite) GetNote(ctx context.Context, userId, noteId int) (models.Note, error) {
+	const op = "storage.sqlite.GetNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return models.Note{}, err
+	}
+
+	var (
+		n    models.Note
+		tags sql.NullString
+	)
+	n.ID = noteId
+
+	query := fmt.Sprintf(
+		"SELECT n.user_id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at FROM %s n WHERE n.id = ?",
+		noteTagsColumn, storage.NotesTable,
+	)
+	row := r.db.QueryRowContext(ctx, query, noteId)
+	if err := row.Scan(&n.UserID, &n.Title, &n.Content, &tags, &n.NotebookID, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		return models.Note{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	n.Tags = splitTags(tags)
+
+	return n, nil
+}
+
+func (r *NoteRepoSQLite) UpdateNote(ctx context.Context, userId, noteId int, note models.UpdateNoteInput) error {
+	const op = "storage.sqlite.UpdateNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	setValues := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if note.Title != nil {
+		setValues = append(setValues, "title = ?")
+		args = append(args, *note.Title)
+	}
+
+	if note.Content != nil {
+		setValues = append(setValues, "content = ?")
+		args = append(args, *note.Content)
+	}
+
+	setValues = append(setValues, "updated_at = ?")
+	args = append(args, now(), noteId, userId)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		storage.NotesTable,
+		strings.Join(setValues, ", "),
+	)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	if err = validateNoteId(ctx, tx, userId, noteId, false); err != nil {
+		return err
+	}
+
+	if note.Title != nil || note.Content != nil {
+		if err = addRevision(ctx, tx, userId, noteId, note, r.maxRevisions); err != nil {
+			return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+	}
+
+	// the note may have been trashed since it was checked, the revision is
+	// rolled back along with the update then
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	if note.Tags != nil {
+		if err = setNoteTags(ctx, tx, userId, noteId, *note.Tags); err != nil {
+			return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoSQLite) MoveNote(ctx context.Context, userId, noteId int, notebookId *int) error {
+	const op = "storage.sqlite.MoveNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return err
+	}
+	if err := checkNotebook(ctx, r.db, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET notebook_id = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		storage.NotesTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, notebookId, noteId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *NoteRepoSQLite) DeleteNote(ctx context.Context, userId, noteId int) error {
+	const op = "storage.sqlite.DeleteNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		storage.NotesTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, now(), noteId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoSQLite) GetTrash(ctx context.Context, userId, limit, offset int) ([]models.NoteDTO, error) {
+	const op = "storage.sqlite.GetTrash"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var notes []models.NoteDTO
+
+	query := fmt.Sprintf(
+		`SELECT n.id, n.title, n.content, %s, n.notebook_id, n.created_at, n.updated_at, n.deleted_at
+		 FROM %s n
+		 WHERE n.user_id = ? AND n.deleted_at IS NOT NULL
+		 ORDER BY n.deleted_at DESC, n.id DESC
+		 LIMIT ? OFFSET ?`,
+		noteTagsColumn, storage.NotesTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			n    models.NoteDTO
+			tags sql.NullString
+		)
+
+		err = rows.Scan(&n.ID, &n.Title, &n.Content, &tags, &n.NotebookID, &n.CreatedAt, &n.UpdatedAt, &n.DeletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		n.Tags = splitTags(tags)
+
+		notes = append(notes, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return notes, nil
+}
+
+func (r *NoteRepoSQLite) RestoreNote(ctx context.Context, userId, noteId int) error {
+	const op = "storage.sqlite.RestoreNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, true); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = ? AND user_id = ?", storage.NotesTable)
+	if _, err := r.db.ExecContext(ctx, query, noteId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoSQLite) PurgeNote(ctx context.Context, userId, noteId int) error {
+	const op = "storage.sqlite.PurgeNote"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, true); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL",
+		storage.NotesTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, noteId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NoteRepoSQLite) PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int, error) {
+	const op = "storage.sqlite.PurgeTrash"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		`DELETE FROM %[1]s WHERE id IN (
+		     SELECT id FROM %[1]s WHERE deleted_at < ? LIMIT ?
+		 )`,
+		storage.NotesTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, deletedBefore.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return int(n), nil
+}
+
+// validateNoteId checks that the note exists and belongs to the user. trashed
+// tells whether the note is expected in the trash or outside of it, a note on
+// the other side counts as missing.
+func validateNoteId(ctx context.Context, q querier, userId, noteId int, trashed bool) error {
+	var (
+		ownerID   int
+		isTrashed bool
+	)
+
+	query := fmt.Sprintf("SELECT user_id, deleted_at IS NOT NULL FROM %s WHERE id = ?", storage.NotesTable)
+	err := q.QueryRowContext(ctx, query, noteId).Scan(&ownerID, &isTrashed)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && isTrashed != trashed) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return ctxErr(ctx, err)
+	}
+	if ownerID != userId {
+		return storage.ErrAccessDenied
+	}
+
+	return nil
+}
+
+// setNoteTags replaces the tags of a note, creating the ones the user does not have yet.
+func setNoteTags(ctx context.Context, tx *sql.Tx, userId, noteId int, tags []string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE note_id = ?", storage.NoteTagsTable)
+	if _, err := tx.ExecContext(ctx, query, noteId); err != nil {
+		return err
+	}
+
+	insertTag := fmt.Sprintf(
+		"INSERT INTO %s (user_id, name, created_at) VALUES (?, ?, ?) ON CONFLICT (user_id, name) DO NOTHING",
+		storage.TagsTable,
+	)
+	attachTag := fmt.Sprintf(
+		"INSERT INTO %s (note_id, tag_id) SELECT ?, id FROM %s WHERE user_id = ? AND name = ?",
+		storage.NoteTagsTable, storage.TagsTable,
+	)
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, insertTag, userId, tag, now()); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, attachTag, noteId, userId, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitTags parses noteTagsColumn, tag names never contain commas.
+func splitTags(tags sql.NullString) []string {
+	if !tags.Valid || tags.String == "" {
+		return []string{}
+	}
+	return strings.Split(tags.String, ",")
+}
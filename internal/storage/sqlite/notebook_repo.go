@@ -0,0 +1,238 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type NotebookRepoSQLite struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewNotebookRepoSQLite(db *sql.DB, timeout time.Duration) *NotebookRepoSQLite {
+	return &NotebookRepoSQLite{db: db, timeout: timeout}
+}
+
+// querier is what *sql.DB and *sql.Tx have in common for single row lookups.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *NotebookRepoSQLite) CreateNotebook(ctx context.Context, nb models.Notebook) (int, error) {
+	const op = "storage.sqlite.CreateNotebook"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := checkNotebook(ctx, r.db, nb.UserID, nb.ParentID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, parent_id, name, created_at, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		storage.NotebooksTable,
+	)
+	created := now()
+	if err := r.db.QueryRowContext(ctx, query, nb.UserID, nb.ParentID, nb.Name, created, created).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return id, nil
+}
+
+func (r *NotebookRepoSQLite) GetNotebooks(ctx context.Context, userId int) ([]models.Notebook, error) {
+	const op = "storage.sqlite.GetNotebooks"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	notebooks := []models.Notebook{}
+
+	query := fmt.Sprintf(
+		"SELECT id, parent_id, name, created_at, updated_at FROM %s WHERE user_id = ? ORDER BY name, id",
+		storage.NotebooksTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		nb := models.Notebook{UserID: userId}
+		if err = rows.Scan(&nb.ID, &nb.ParentID, &nb.Name, &nb.CreatedAt, &nb.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		notebooks = append(notebooks, nb)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return notebooks, nil
+}
+
+func (r *NotebookRepoSQLite) GetNotebook(ctx context.Context, userId, notebookId int) (models.Notebook, error) {
+	const op = "storage.sqlite.GetNotebook"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	nb := models.Notebook{ID: notebookId}
+
+	query := fmt.Sprintf(
+		"SELECT user_id, parent_id, name, created_at, updated_at FROM %s WHERE id = ?",
+		storage.NotebooksTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, notebookId).Scan(&nb.UserID, &nb.ParentID, &nb.Name, &nb.CreatedAt, &nb.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Notebook{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.Notebook{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if nb.UserID != userId {
+		return models.Notebook{}, fmt.Errorf("%s: %w", op, storage.ErrAccessDenied)
+	}
+
+	return nb, nil
+}
+
+func (r *NotebookRepoSQLite) RenameNotebook(ctx context.Context, userId, notebookId int, name string) error {
+	const op = "storage.sqlite.RenameNotebook"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNotebookId(ctx, r.db, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET name = ?, updated_at = ? WHERE id = ? AND user_id = ?", storage.NotebooksTable)
+	if _, err := r.db.ExecContext(ctx, query, name, now(), notebookId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+// MoveNotebook refuses to move a notebook below itself, which would detach
+// the whole branch from the tree. The single connection serializes moves, so
+// the cycle check cannot race another move.
+func (r *NotebookRepoSQLite) MoveNotebook(ctx context.Context, userId, notebookId int, parentId *int) error {
+	const op = "storage.sqlite.MoveNotebook"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	if err = validateNotebookId(ctx, tx, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if parentId != nil {
+		if err = checkNotebook(ctx, tx, userId, parentId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		var cycle bool
+		query := fmt.Sprintf(
+			`WITH RECURSIVE ancestors (id, parent_id) AS (
+			     SELECT id, parent_id FROM %[1]s WHERE id = ?
+			     UNION
+			     SELECT nb.id, nb.parent_id FROM %[1]s nb JOIN ancestors a ON nb.id = a.parent_id
+			 )
+			 SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`,
+			storage.NotebooksTable,
+		)
+		if err = tx.QueryRowContext(ctx, query, *parentId, notebookId).Scan(&cycle); err != nil {
+			return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		if cycle {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotebookCycle)
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET parent_id = ?, updated_at = ? WHERE id = ?", storage.NotebooksTable)
+	if _, err = tx.ExecContext(ctx, query, parentId, now(), notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *NotebookRepoSQLite) DeleteNotebook(ctx context.Context, userId, notebookId int) error {
+	const op = "storage.sqlite.DeleteNotebook"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNotebookId(ctx, r.db, userId, notebookId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// nested notebooks go with it and their notes are unfiled by the foreign keys
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = ? AND user_id = ?", storage.NotebooksTable)
+	if _, err := r.db.ExecContext(ctx, query, notebookId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+// validateNotebookId checks that the notebook exists and belongs to the user.
+func validateNotebookId(ctx context.Context, q querier, userId, notebookId int) error {
+	var ownerID int
+
+	query := fmt.Sprintf("SELECT user_id FROM %s WHERE id = ?", storage.NotebooksTable)
+	err := q.QueryRowContext(ctx, query, notebookId).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return ctxErr(ctx, err)
+	}
+	if ownerID != userId {
+		return storage.ErrAccessDenied
+	}
+
+	return nil
+}
+
+// checkNotebook validates a notebook referenced from a request body, a
+// missing notebook and someone else's look the same to the caller.
+func checkNotebook(ctx context.Context, q querier, userId int, notebookId *int) error {
+	if notebookId == nil {
+		return nil
+	}
+
+	var exists bool
+
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = ? AND user_id = ?)", storage.NotebooksTable)
+	if err := q.QueryRowContext(ctx, query, *notebookId, userId).Scan(&exists); err != nil {
+		return ctxErr(ctx, err)
+	}
+	if !exists {
+		return storage.ErrInvalidNotebook
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type PasswordResetRepoSQLite struct {
+	db      *sql.DB
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func NewPasswordResetRepoSQLite(db *sql.DB, ttl time.Duration, timeout time.Duration) *PasswordResetRepoSQLite {
+	return &PasswordResetRepoSQLite{db: db, ttl: ttl, timeout: timeout}
+}
+
+// CreateResetToken issues a reset token and invalidates the ones requested before it.
+func (r *PasswordResetRepoSQLite) CreateResetToken(ctx context.Context, userId int) (string, error) {
+	const op = "storage.sqlite.CreateResetToken"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	token, err := storage.RandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+		storage.PasswordResetTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, now(), userId); err != nil {
+		return "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)",
+		storage.PasswordResetTable,
+	)
+	created := now()
+	if _, err = tx.ExecContext(ctx, query, userId, storage.HashToken(token), created.Add(r.ttl), created); err != nil {
+		return "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return token, nil
+}
+
+// ConsumeResetToken marks the token as used and returns the user it was issued to.
+func (r *PasswordResetRepoSQLite) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	const op = "storage.sqlite.ConsumeResetToken"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var userId int
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET used_at = ?1
+		 WHERE token_hash = ?2 AND used_at IS NULL AND expires_at > ?1
+		 RETURNING user_id`,
+		storage.PasswordResetTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, now(), storage.HashToken(token)).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,186 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RefreshTokenRepoSQLite struct {
+	db      *sql.DB
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func NewRefreshTokenRepoSQLite(db *sql.DB, ttl time.Duration, timeout time.Duration) *RefreshTokenRepoSQLite {
+	return &RefreshTokenRepoSQLite{db: db, ttl: ttl, timeout: timeout}
+}
+
+// CreateRefreshToken starts a new token family for the user and returns its first token.
+func (r *RefreshTokenRepoSQLite) CreateRefreshToken(ctx context.Context, userId int) (string, error) {
+	const op = "storage.sqlite.CreateRefreshToken"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	familyId, err := storage.RandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := r.insertRefreshToken(ctx, r.db, userId, familyId)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same family.
+// Presenting a token that was already rotated revokes the whole family.
+func (r *RefreshTokenRepoSQLite) RotateRefreshToken(ctx context.Context, token string) (int, string, error) {
+	const op = "storage.sqlite.RotateRefreshToken"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	var (
+		id, userId int
+		familyId   string
+		expiresAt  time.Time
+		usedAt     sql.NullTime
+		revokedAt  sql.NullTime
+	)
+
+	query := fmt.Sprintf(
+		`SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		 FROM %s
+		 WHERE token_hash = ?`,
+		storage.RefreshTokensTable,
+	)
+	err = tx.QueryRowContext(ctx, query, storage.HashToken(token)).Scan(&id, &userId, &familyId, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", storage.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if usedAt.Valid {
+		if err = revokeFamily(ctx, tx, familyId); err != nil {
+			return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		if err = tx.Commit(); err != nil {
+			return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		return 0, "", storage.ErrRefreshTokenReused
+	}
+	if revokedAt.Valid || now().After(expiresAt) {
+		return 0, "", storage.ErrInvalidRefreshToken
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET used_at = ? WHERE id = ?", storage.RefreshTokensTable)
+	if _, err = tx.ExecContext(ctx, query, now(), id); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	newToken, err := r.insertRefreshToken(ctx, tx, userId, familyId)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return userId, newToken, nil
+}
+
+// RevokeRefreshToken revokes the family of a refresh token owned by the user.
+func (r *RefreshTokenRepoSQLite) RevokeRefreshToken(ctx context.Context, userId int, token string) error {
+	const op = "storage.sqlite.RevokeRefreshToken"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		`UPDATE %[1]s SET revoked_at = ?
+		 WHERE revoked_at IS NULL AND family_id = (
+		     SELECT family_id FROM %[1]s WHERE token_hash = ? AND user_id = ?
+		 )`,
+		storage.RefreshTokensTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, now(), storage.HashToken(token), userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrInvalidRefreshToken
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepoSQLite) RevokeUserRefreshTokens(ctx context.Context, userId int) error {
+	const op = "storage.sqlite.RevokeUserRefreshTokens"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		storage.RefreshTokensTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, now(), userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *RefreshTokenRepoSQLite) insertRefreshToken(ctx context.Context, q queryRower, userId int, familyId string) (string, error) {
+	token, err := storage.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		storage.RefreshTokensTable,
+	)
+	var id int
+	created := now()
+	err = q.QueryRowContext(ctx, query, userId, familyId, storage.HashToken(token), created.Add(r.ttl), created).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func revokeFamily(ctx context.Context, tx *sql.Tx, familyId string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL",
+		storage.RefreshTokensTable,
+	)
+	_, err := tx.ExecContext(ctx, query, now(), familyId)
+	return err
+}
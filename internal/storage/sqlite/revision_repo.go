@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RevisionRepoSQLite struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRevisionRepoSQLite(db *sql.DB, timeout time.Duration) *RevisionRepoSQLite {
+	return &RevisionRepoSQLite{db: db, timeout: timeout}
+}
+
+func (r *RevisionRepoSQLite) GetRevisions(ctx context.Context, userId, noteId, limit, offset int) ([]models.NoteRevision, error) {
+	const op = "storage.sqlite.GetRevisions"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return nil, err
+	}
+
+	revisions := []models.NoteRevision{}
+
+	query := fmt.Sprintf(
+		`SELECT id, note_id, author_id, title, content, created_at
+		 FROM %s
+		 WHERE note_id = ?
+		 ORDER BY id DESC
+		 LIMIT ? OFFSET ?`,
+		storage.NoteRevisionsTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, noteId, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev models.NoteRevision
+
+		err = rows.Scan(&rev.ID, &rev.NoteID, &rev.AuthorID, &rev.Title, &rev.Content, &rev.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return revisions, nil
+}
+
+func (r *RevisionRepoSQLite) GetRevision(ctx context.Context, userId, noteId, revisionId int) (models.NoteRevision, error) {
+	const op = "storage.sqlite.GetRevision"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := validateNoteId(ctx, r.db, userId, noteId, false); err != nil {
+		return models.NoteRevision{}, err
+	}
+
+	var rev models.NoteRevision
+
+	query := fmt.Sprintf(
+		"SELECT id, note_id, author_id, title, content, created_at FROM %s WHERE id = ? AND note_id = ?",
+		storage.NoteRevisionsTable,
+	)
+	err := r.db.QueryRowContext(ctx, query, revisionId, noteId).
+		Scan(&rev.ID, &rev.NoteID, &rev.AuthorID, &rev.Title, &rev.Content, &rev.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NoteRevision{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.NoteRevision{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return rev, nil
+}
+
+// addRevision keeps the note's current title and content before update
+// changes them, nothing is kept if neither would change. Beyond maxRevisions
+// the oldest revisions of the note are dropped.
+func addRevision(ctx context.Context, tx *sql.Tx, authorId, noteId int, update models.UpdateNoteInput, maxRevisions int) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (note_id, author_id, title, content, created_at)
+		 SELECT id, ?2, title, content, ?3 FROM %s
+		 WHERE id = ?1 AND deleted_at IS NULL
+		   AND (title <> coalesce(?4, title) OR content <> coalesce(?5, content))`,
+		storage.NoteRevisionsTable, storage.NotesTable,
+	)
+	res, err := tx.ExecContext(ctx, query, noteId, authorId, now(), update.Title, update.Content)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 || maxRevisions <= 0 {
+		return nil
+	}
+
+	query = fmt.Sprintf(
+		`DELETE FROM %[1]s WHERE note_id = ?1 AND id NOT IN (
+		     SELECT id FROM %[1]s WHERE note_id = ?1 ORDER BY id DESC LIMIT ?2
+		 )`,
+		storage.NoteRevisionsTable,
+	)
+	_, err = tx.ExecContext(ctx, query, noteId, maxRevisions)
+	return err
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+)
+
+type RevocationRepoSQLite struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRevocationRepoSQLite(db *sql.DB, timeout time.Duration) *RevocationRepoSQLite {
+	return &RevocationRepoSQLite{db: db, timeout: timeout}
+}
+
+func (r *RevocationRepoSQLite) RevokeToken(ctx context.Context, jti string, userId int, expiresAt time.Time) error {
+	const op = "storage.sqlite.RevokeToken"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (jti, user_id, expires_at, revoked_at) VALUES (?, ?, ?, ?) ON CONFLICT (jti) DO NOTHING",
+		storage.RevokedTokensTable,
+	)
+	if _, err := r.db.ExecContext(ctx, query, jti, userId, expiresAt.UTC(), now()); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	// expired tokens are rejected anyway, no need to remember them
+	query = fmt.Sprintf("DELETE FROM %s WHERE expires_at < ?", storage.RevokedTokensTable)
+	if _, err := r.db.ExecContext(ctx, query, now()); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (r *RevocationRepoSQLite) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	const op = "storage.sqlite.IsTokenRevoked"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var exists bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE jti = ?)", storage.RevokedTokensTable)
+	if err := r.db.QueryRowContext(ctx, query, jti).Scan(&exists); err != nil {
+		return false, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return exists, nil
+}
+
+// RevokeAllTokens invalidates every token issued to the user before now.
+func (r *RevocationRepoSQLite) RevokeAllTokens(ctx context.Context, userId int) (time.Time, error) {
+	const op = "storage.sqlite.RevokeAllTokens"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// iat has a one second resolution, tokens issued later within the same second stay valid
+	before := now().Truncate(time.Second)
+
+	query := fmt.Sprintf("UPDATE %s SET tokens_revoked_before = ? WHERE id = ?", storage.UsersTable)
+	if _, err := r.db.ExecContext(ctx, query, before, userId); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return before, nil
+}
+
+// TokensRevokedBefore returns the user's revocation watermark, zero if there is none.
+func (r *RevocationRepoSQLite) TokensRevokedBefore(ctx context.Context, userId int) (time.Time, error) {
+	const op = "storage.sqlite.TokensRevokedBefore"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var before sql.NullTime
+	query := fmt.Sprintf("SELECT tokens_revoked_before FROM %s WHERE id = ?", storage.UsersTable)
+	err := r.db.QueryRowContext(ctx, query, userId).Scan(&before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return before.Time, nil
+}
@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// now is the timestamp written to the database. Times are always stored in UTC
+// because SQLite compares them as text.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// ctxErr prefers the context's error over the driver's, so a cancelled or
+// timed out query can be told apart from a failed one.
+func ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// placeholders returns n comma separated bind parameters for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
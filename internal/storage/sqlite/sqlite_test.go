@@ -0,0 +1,41 @@
+package sqlite_test
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/sqlite"
+	"github/yusupovkuzs/GoNotesApp/internal/storage/storagetest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRepositories(t *testing.T) {
+	s, err := storage.NewStorageSQLite(config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "notes.db")})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { s.DB.Close() })
+
+	migrator, err := storage.NewMigrator(s.DB, "sqlite")
+	if err != nil {
+		t.Fatalf("migrations setup: %v", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+
+	db, timeout := s.DB, 5*time.Second
+	storagetest.Run(t, storage.Repositories{
+		Notes:          sqlite.NewNoteRepoSQLite(db, storagetest.MaxRevisions, timeout),
+		Tags:           sqlite.NewTagRepoSQLite(db, timeout),
+		Notebooks:      sqlite.NewNotebookRepoSQLite(db, timeout),
+		Revisions:      sqlite.NewRevisionRepoSQLite(db, timeout),
+		Users:          sqlite.NewUserRepoSQLite(db, storagetest.Hasher(), timeout),
+		RefreshTokens:  sqlite.NewRefreshTokenRepoSQLite(db, 24*time.Hour, timeout),
+		MFA:            sqlite.NewMFARepoSQLite(db, timeout),
+		PasswordResets: sqlite.NewPasswordResetRepoSQLite(db, time.Hour, timeout),
+		Revocations:    sqlite.NewRevocationRepoSQLite(db, timeout),
+	})
+}
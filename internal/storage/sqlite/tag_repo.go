@@ -0,0 +1,177 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type TagRepoSQLite struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewTagRepoSQLite(db *sql.DB, timeout time.Duration) *TagRepoSQLite {
+	return &TagRepoSQLite{db: db, timeout: timeout}
+}
+
+// ListTags returns the user's tags by name, with the number of notes carrying
+// each. Trashed notes are not counted.
+func (r *TagRepoSQLite) ListTags(ctx context.Context, userId int) ([]models.Tag, error) {
+	const op = "storage.sqlite.ListTags"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tags := []models.Tag{}
+
+	query := fmt.Sprintf(
+		`SELECT t.name, count(n.id)
+		 FROM %s t
+		 LEFT JOIN %s nt ON nt.tag_id = t.id
+		 LEFT JOIN %s n ON n.id = nt.note_id AND n.deleted_at IS NULL
+		 WHERE t.user_id = ?
+		 GROUP BY t.id
+		 ORDER BY t.name`,
+		storage.TagsTable, storage.NoteTagsTable, storage.NotesTable,
+	)
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Tag
+		if err = rows.Scan(&t.Name, &t.NoteCount); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+		}
+		tags = append(tags, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return tags, nil
+}
+
+// RenameTag fails with storage.ErrTagExists if newName is taken, MergeTag joins two tags.
+func (r *TagRepoSQLite) RenameTag(ctx context.Context, userId int, name, newName string) error {
+	const op = "storage.sqlite.RenameTag"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf("UPDATE %s SET name = ? WHERE user_id = ? AND name = ?", storage.TagsTable)
+	res, err := r.db.ExecContext(ctx, query, newName, userId, name)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return fmt.Errorf("%s: %w", op, storage.ErrTagExists)
+		}
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// MergeTag moves the notes tagged name over to into, creating it if needed,
+// and deletes name.
+func (r *TagRepoSQLite) MergeTag(ctx context.Context, userId int, name, into string) error {
+	const op = "storage.sqlite.MergeTag"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	defer tx.Rollback()
+
+	var sourceId, targetId int
+
+	query := fmt.Sprintf("SELECT id FROM %s WHERE user_id = ? AND name = ?", storage.TagsTable)
+	err = tx.QueryRowContext(ctx, query, userId, name).Scan(&sourceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	// the no-op update makes RETURNING yield the id of an existing tag too
+	query = fmt.Sprintf(
+		`INSERT INTO %s (user_id, name, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT (user_id, name) DO UPDATE SET name = excluded.name
+		 RETURNING id`,
+		storage.TagsTable,
+	)
+	if err = tx.QueryRowContext(ctx, query, userId, into, now()).Scan(&targetId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if targetId == sourceId {
+		return nil
+	}
+
+	query = fmt.Sprintf(
+		`INSERT INTO %[1]s (note_id, tag_id)
+		 SELECT note_id, ? FROM %[1]s WHERE tag_id = ?
+		 ON CONFLICT DO NOTHING`,
+		storage.NoteTagsTable,
+	)
+	if _, err = tx.ExecContext(ctx, query, targetId, sourceId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE id = ?", storage.TagsTable)
+	if _, err = tx.ExecContext(ctx, query, sourceId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+// DeleteTag removes the tag from the user's notes, the notes themselves stay.
+func (r *TagRepoSQLite) DeleteTag(ctx context.Context, userId int, name string) error {
+	const op = "storage.sqlite.DeleteTag"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = ? AND name = ?", storage.TagsTable)
+	res, err := r.db.ExecContext(ctx, query, userId, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
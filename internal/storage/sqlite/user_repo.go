@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/password"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type UserRepoSQLite struct {
+	db      *sql.DB
+	hasher  *password.Hasher
+	timeout time.Duration
+}
+
+func NewUserRepoSQLite(db *sql.DB, hasher *password.Hasher, timeout time.Duration) *UserRepoSQLite {
+	return &UserRepoSQLite{db: db, hasher: hasher, timeout: timeout}
+}
+
+func (r *UserRepoSQLite) CreateUser(ctx context.Context, u models.User) (int, error) {
+	const op = "storage.sqlite.CreateUser"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var id int
+	hash, err := r.hasher.Hash(u.Password)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (username, password_hash, email, created_at) VALUES (?, ?, NULLIF(?, ''), ?) RETURNING id",
+		storage.UsersTable,
+	)
+	if err := r.db.QueryRowContext(ctx, query, u.Username, hash, u.Email, now()).Scan(&id); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			if strings.Contains(sqliteErr.Error(), "users_email_key") {
+				return 0, storage.ErrEmailTaken
+			}
+			return 0, storage.ErrUsernameTaken
+		}
+		return 0, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return id, nil
+}
+
+const userColumns = "id, username, password_hash, totp_enabled, email, email_verified_at, created_at"
+
+func scanUser(row *sql.Row) (models.User, error) {
+	var (
+		user       models.User
+		email      sql.NullString
+		verifiedAt sql.NullTime
+	)
+
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.TOTPEnabled, &email, &verifiedAt, &user.CreatedAt)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.Email = email.String
+	user.EmailVerified = verifiedAt.Valid
+	return user, nil
+}
+
+func (r *UserRepoSQLite) GetUser(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.sqlite.GetUser"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE username = ?", userColumns, storage.UsersTable)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return user, nil
+}
+
+func (r *UserRepoSQLite) GetUserByID(ctx context.Context, userId int) (models.User, error) {
+	const op = "storage.sqlite.GetUserByID"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", userColumns, storage.UsersTable)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return user, nil
+}
+
+// MarkEmailVerified verifies the user's email, provided it hasn't changed since the link was sent.
+func (r *UserRepoSQLite) MarkEmailVerified(ctx context.Context, userId int, email string) error {
+	const op = "storage.sqlite.MarkEmailVerified"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET email_verified_at = COALESCE(email_verified_at, ?)
+		 WHERE id = ? AND lower(email) = lower(?)`,
+		storage.UsersTable,
+	)
+	res, err := r.db.ExecContext(ctx, query, now(), userId, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+	if n == 0 {
+		return storage.ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// VerifyPassword re-confirms the password of an already authenticated user.
+func (r *UserRepoSQLite) VerifyPassword(ctx context.Context, userId int, password string) error {
+	const op = "storage.sqlite.VerifyPassword"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user, err := r.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return storage.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (r *UserRepoSQLite) UpdatePasswordHash(ctx context.Context, userId int, hash string) error {
+	const op = "storage.sqlite.UpdatePasswordHash"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf("UPDATE %s SET password_hash = ? WHERE id = ?", storage.UsersTable)
+	if _, err := r.db.ExecContext(ctx, query, hash, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, ctxErr(ctx, err))
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the user's password with a hash of the new one.
+func (r *UserRepoSQLite) UpdatePassword(ctx context.Context, userId int, password string) error {
+	const op = "storage.sqlite.UpdatePassword"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	hash, err := r.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r.UpdatePasswordHash(ctx, userId, hash)
+}
+
+// Authenticate checks the user's password and upgrades its hash when it was
+// produced by an outdated scheme.
+func (r *UserRepoSQLite) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	const op = "storage.sqlite.Authenticate"
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user, err := r.GetUser(ctx, username)
+	if errors.Is(err, storage.ErrNotFound) {
+		r.hasher.VerifyDummy(password)
+		return models.User{}, storage.ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	ok, err := r.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return models.User{}, storage.ErrInvalidCredentials
+	}
+
+	if r.hasher.NeedsRehash(user.PasswordHash) {
+		// best effort, the upgrade is retried on the next successful login
+		if hash, err := r.hasher.Hash(password); err == nil {
+			if err = r.UpdatePasswordHash(ctx, user.ID, hash); err == nil {
+				user.PasswordHash = hash
+			}
+		}
+	}
+
+	return user, nil
+}
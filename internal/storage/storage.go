@@ -5,44 +5,148 @@ import (
 	"errors"
 	"fmt"
 	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
-	"github.com/pressly/goose"
+	_ "modernc.org/sqlite"
 )
 
 const (
-	UsersTable = "users"
-	NotesTable = "notes"
+	UsersTable         = "users"
+	NotesTable         = "notes"
+	TagsTable          = "tags"
+	NoteTagsTable      = "note_tags"
+	NotebooksTable     = "notebooks"
+	NoteRevisionsTable = "note_revisions"
+	RefreshTokensTable = "refresh_tokens"
+	RevokedTokensTable = "revoked_tokens"
+	RecoveryCodesTable = "recovery_codes"
+	PasswordResetTable = "password_reset_tokens"
 )
 
 var (
-	ErrAccessDenied  = errors.New("access denied")
-	ErrUsernameTaken = errors.New("username taken")
+	ErrNotFound                 = errors.New("not found")
+	ErrAccessDenied             = errors.New("access denied")
+	ErrTagExists                = errors.New("tag already exists")
+	ErrInvalidNotebook          = errors.New("notebook does not exist")
+	ErrNotebookCycle            = errors.New("a notebook cannot be moved below itself")
+	ErrUsernameTaken            = errors.New("username taken")
+	ErrEmailTaken               = errors.New("email taken")
+	ErrInvalidCredentials       = errors.New("invalid username or password")
+	ErrMFAAlreadyEnabled        = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled           = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrInvalidRefreshToken      = errors.New("invalid refresh token")
+	ErrRefreshTokenReused       = errors.New("refresh token reuse detected")
 )
 
 type StoragePostgres struct {
 	DB *sql.DB
 }
 
-func NewStoragePostgres(dbInfo config.PostgresConfig) (*StoragePostgres, error) {
-	psqlInfo := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbInfo.Host, dbInfo.Port, dbInfo.User, dbInfo.Password, dbInfo.DBName,
-	)
-	db, err := sql.Open("postgres", psqlInfo)
+// maxConnectBackoff caps the delay between connection attempts at startup.
+const maxConnectBackoff = time.Minute
+
+// NewStoragePostgres opens the connection pool and waits for the database to
+// accept connections, so the app survives being started before it.
+func NewStoragePostgres(cfg config.PostgresConfig, log *slog.Logger) (*StoragePostgres, error) {
+	db, err := sql.Open("postgres", postgresDSN(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err = db.Ping()
+		if err == nil {
+			break
+		}
+		if attempt >= cfg.ConnectAttempts {
+			db.Close()
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		log.Warn("database is not ready, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			sl.Err(err),
+		)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
+
 	return &StoragePostgres{DB: db}, nil
 }
 
-func RunMigrations(db *sql.DB) error {
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
+// postgresDSN builds a key/value connection string, unless a full URL is configured.
+func postgresDSN(cfg config.PostgresConfig) string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+
+	var statementTimeout string
+	if cfg.StatementTimeout > 0 {
+		statementTimeout = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	params := []struct{ key, value string }{
+		{"host", cfg.Host},
+		{"port", cfg.Port},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBName},
+		{"sslmode", cfg.SSLMode},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"application_name", cfg.ApplicationName},
+		// unknown keys are sent to the server as run-time parameters
+		{"statement_timeout", statementTimeout},
+	}
+
+	var parts []string
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a value so spaces and quotes in passwords survive.
+func quoteDSNValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+type StorageSQLite struct {
+	DB *sql.DB
+}
+
+func NewStorageSQLite(cfg config.SQLiteConfig) (*StorageSQLite, error) {
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+		cfg.Path,
+	)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	// SQLite has a single writer, sharing one connection keeps transactions from failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		return nil, fmt.Errorf("%w", err)
 	}
-	return goose.Up(db, "migrations")
+	return &StorageSQLite{DB: db}, nil
 }
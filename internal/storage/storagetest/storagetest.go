@@ -0,0 +1,708 @@
+// Package storagetest is the conformance suite every storage backend has to
+// pass, so the backends stay interchangeable.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github/yusupovkuzs/GoNotesApp/internal/models"
+	"github/yusupovkuzs/GoNotesApp/internal/search"
+	"github/yusupovkuzs/GoNotesApp/internal/storage"
+	"github/yusupovkuzs/GoNotesApp/pkg/password"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// MaxRevisions is the revision limit the note repository under test has to
+// be created with.
+const MaxRevisions = 3
+
+// Hasher returns a password hasher cheap enough for tests.
+func Hasher() *password.Hasher {
+	return password.NewHasher(password.NewBcrypt(4))
+}
+
+// userSeq keeps usernames unique when the suite runs repeatedly against a
+// database that is not reset in between.
+var userSeq atomic.Int64
+
+// Run runs the suite against repos. The subtests only look at the users they
+// create, so the repositories may hold data of earlier runs.
+func Run(t *testing.T, repos storage.Repositories) {
+	s := &suite{repos: repos, run: time.Now().UnixNano()}
+
+	t.Run("Users", s.testUsers)
+	t.Run("NoteCRUD", s.testNoteCRUD)
+	t.Run("NoteOwnership", s.testNoteOwnership)
+	t.Run("NoteListing", s.testNoteListing)
+	t.Run("Search", s.testSearch)
+	t.Run("Tags", s.testTags)
+	t.Run("Notebooks", s.testNotebooks)
+	t.Run("Trash", s.testTrash)
+	t.Run("Revisions", s.testRevisions)
+	t.Run("RefreshTokens", s.testRefreshTokens)
+	t.Run("MFA", s.testMFA)
+	t.Run("PasswordResets", s.testPasswordResets)
+	t.Run("Revocations", s.testRevocations)
+	t.Run("Concurrent", s.testConcurrent)
+}
+
+type suite struct {
+	repos storage.Repositories
+	run   int64
+}
+
+func (s *suite) user(t *testing.T) int {
+	t.Helper()
+
+	name := fmt.Sprintf("u%d_%d", s.run%1e9, userSeq.Add(1))
+	id, err := s.repos.Users.CreateUser(context.Background(), models.User{Username: name, Password: "password123"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return id
+}
+
+func (s *suite) note(t *testing.T, userId int, title string, tags ...string) int {
+	t.Helper()
+
+	id, err := s.repos.Notes.CreateNote(context.Background(), models.Note{
+		UserID:  userId,
+		Title:   title,
+		Content: title + " content",
+		Tags:    tags,
+	})
+	if err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+	return id
+}
+
+func (s *suite) titles(t *testing.T, userId int, filter storage.NoteFilter) []string {
+	t.Helper()
+
+	if filter.Limit == 0 {
+		filter.Limit = 100
+	}
+	if filter.Sort == "" {
+		filter.Sort = "asc"
+	}
+	notes, err := s.repos.Notes.GetAllNotes(context.Background(), userId, filter)
+	if err != nil {
+		t.Fatalf("GetAllNotes: %v", err)
+	}
+
+	titles := []string{}
+	for _, n := range notes {
+		titles = append(titles, n.Title)
+	}
+	return titles
+}
+
+func wantErr(t *testing.T, what string, err, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Fatalf("%s: got error %v, want %v", what, err, want)
+	}
+}
+
+func noErr(t *testing.T, what string, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+func wantStrings(t *testing.T, what string, got, want []string) {
+	t.Helper()
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("%s: got %q, want %q", what, got, want)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func (s *suite) testUsers(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("users%d_%d", s.run%1e9, userSeq.Add(1))
+
+	id, err := s.repos.Users.CreateUser(ctx, models.User{Username: name, Password: "password123"})
+	noErr(t, "CreateUser", err)
+
+	_, err = s.repos.Users.CreateUser(ctx, models.User{Username: name, Password: "password123"})
+	wantErr(t, "CreateUser with a taken username", err, storage.ErrUsernameTaken)
+
+	u, err := s.repos.Users.Authenticate(ctx, name, "password123")
+	noErr(t, "Authenticate", err)
+	if u.ID != id {
+		t.Fatalf("Authenticate returned user %d, want %d", u.ID, id)
+	}
+
+	_, err = s.repos.Users.Authenticate(ctx, name, "wrong password")
+	wantErr(t, "Authenticate with a wrong password", err, storage.ErrInvalidCredentials)
+	_, err = s.repos.Users.Authenticate(ctx, name+"_missing", "password123")
+	wantErr(t, "Authenticate an unknown user", err, storage.ErrInvalidCredentials)
+
+	_, err = s.repos.Users.GetUserByID(ctx, id+1_000_000)
+	wantErr(t, "GetUserByID of a missing user", err, storage.ErrNotFound)
+}
+
+func (s *suite) testNoteCRUD(t *testing.T) {
+	ctx := context.Background()
+	user := s.user(t)
+
+	id, err := s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: "first", Content: "body", Tags: []string{"b", "a"}})
+	noErr(t, "CreateNote", err)
+
+	n, err := s.repos.Notes.GetNote(ctx, user, id)
+	noErr(t, "GetNote", err)
+	if n.ID != id || n.UserID != user || n.Title != "first" || n.Content != "body" {
+		t.Fatalf("GetNote returned %+v", n)
+	}
+	wantStrings(t, "tags", n.Tags, []string{"a", "b"})
+	if n.CreatedAt.IsZero() || n.UpdatedAt.IsZero() {
+		t.Fatalf("GetNote returned zero timestamps: %+v", n)
+	}
+
+	noErr(t, "UpdateNote", s.repos.Notes.UpdateNote(ctx, user, id, models.UpdateNoteInput{Content: ptr("new body")}))
+	n, err = s.repos.Notes.GetNote(ctx, user, id)
+	noErr(t, "GetNote", err)
+	if n.Title != "first" || n.Content != "new body" {
+		t.Fatalf("partial update changed the wrong fields: %+v", n)
+	}
+	wantStrings(t, "tags after an update without tags", n.Tags, []string{"a", "b"})
+
+	noErr(t, "UpdateNote", s.repos.Notes.UpdateNote(ctx, user, id, models.UpdateNoteInput{Tags: &[]string{}}))
+	n, err = s.repos.Notes.GetNote(ctx, user, id)
+	noErr(t, "GetNote", err)
+	wantStrings(t, "tags after clearing them", n.Tags, []string{})
+
+	noErr(t, "DeleteNote", s.repos.Notes.DeleteNote(ctx, user, id))
+	_, err = s.repos.Notes.GetNote(ctx, user, id)
+	wantErr(t, "GetNote after delete", err, storage.ErrNotFound)
+}
+
+func (s *suite) testNoteOwnership(t *testing.T) {
+	ctx := context.Background()
+	owner, other := s.user(t), s.user(t)
+	id := s.note(t, owner, "private")
+	missing := id + 1_000_000
+
+	_, err := s.repos.Notes.GetNote(ctx, other, id)
+	wantErr(t, "GetNote of another user's note", err, storage.ErrAccessDenied)
+	err = s.repos.Notes.UpdateNote(ctx, other, id, models.UpdateNoteInput{Title: ptr("mine")})
+	wantErr(t, "UpdateNote of another user's note", err, storage.ErrAccessDenied)
+	err = s.repos.Notes.MoveNote(ctx, other, id, nil)
+	wantErr(t, "MoveNote of another user's note", err, storage.ErrAccessDenied)
+	err = s.repos.Notes.DeleteNote(ctx, other, id)
+	wantErr(t, "DeleteNote of another user's note", err, storage.ErrAccessDenied)
+	_, err = s.repos.Revisions.GetRevisions(ctx, other, id, 10, 0)
+	wantErr(t, "GetRevisions of another user's note", err, storage.ErrAccessDenied)
+
+	_, err = s.repos.Notes.GetNote(ctx, owner, missing)
+	wantErr(t, "GetNote of a missing note", err, storage.ErrNotFound)
+	err = s.repos.Notes.UpdateNote(ctx, owner, missing, models.UpdateNoteInput{Title: ptr("x")})
+	wantErr(t, "UpdateNote of a missing note", err, storage.ErrNotFound)
+	err = s.repos.Notes.DeleteNote(ctx, owner, missing)
+	wantErr(t, "DeleteNote of a missing note", err, storage.ErrNotFound)
+
+	wantStrings(t, "other user's notes", s.titles(t, other, storage.NoteFilter{}), []string{})
+
+	n, err := s.repos.Notes.GetNote(ctx, owner, id)
+	noErr(t, "GetNote", err)
+	if n.Title != "private" {
+		t.Fatalf("note changed through another user: %+v", n)
+	}
+}
+
+func (s *suite) testNoteListing(t *testing.T) {
+	user := s.user(t)
+	for _, title := range []string{"one", "two", "three"} {
+		s.note(t, user, title)
+		// creation time orders the notes
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	wantStrings(t, "ascending", s.titles(t, user, storage.NoteFilter{}), []string{"one", "two", "three"})
+	wantStrings(t, "descending", s.titles(t, user, storage.NoteFilter{Sort: "desc"}), []string{"three", "two", "one"})
+	wantStrings(t, "paged", s.titles(t, user, storage.NoteFilter{Limit: 1, Offset: 1}), []string{"two"})
+	wantStrings(t, "past the end", s.titles(t, user, storage.NoteFilter{Offset: 3}), []string{})
+}
+
+func (s *suite) testSearch(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+
+	_, err := s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: "gardening", Content: "tomatoes need sun"})
+	noErr(t, "CreateNote", err)
+	_, err = s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: "tomatoes", Content: "a list of tomatoes"})
+	noErr(t, "CreateNote", err)
+	_, err = s.repos.Notes.CreateNote(ctx, models.Note{UserID: other, Title: "tomatoes", Content: "not yours"})
+	noErr(t, "CreateNote", err)
+
+	results, err := s.repos.Notes.SearchNotes(ctx, user, search.Parse("tomatoes"), 10, 0)
+	noErr(t, "SearchNotes", err)
+	if len(results) != 2 {
+		t.Fatalf("SearchNotes found %d notes, want 2", len(results))
+	}
+	if results[0].Title != "tomatoes" {
+		t.Fatalf("a title match should rank first, got %q", results[0].Title)
+	}
+
+	results, err = s.repos.Notes.SearchNotes(ctx, user, search.Parse("cucumbers"), 10, 0)
+	noErr(t, "SearchNotes", err)
+	if len(results) != 0 {
+		t.Fatalf("SearchNotes found %d notes for an absent word", len(results))
+	}
+
+	_, err = s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: "html", Content: "<script>alert(1)</script> peppers & salt"})
+	noErr(t, "CreateNote", err)
+	results, err = s.repos.Notes.SearchNotes(ctx, user, search.Parse("peppers"), 10, 0)
+	noErr(t, "SearchNotes", err)
+	if len(results) != 1 {
+		t.Fatalf("SearchNotes found %d notes, want 1", len(results))
+	}
+	snippet := results[0].Snippet
+	plain := strings.NewReplacer(search.StartSel, "", search.StopSel, "").Replace(snippet)
+	if strings.ContainsAny(plain, "<>") || !strings.Contains(snippet, "&lt;/script&gt;") ||
+		!strings.Contains(snippet, search.StartSel+"peppers"+search.StopSel) || !strings.Contains(snippet, "&amp;") {
+		t.Fatalf("snippet should be escaped apart from the markers, got %q", snippet)
+	}
+}
+
+func (s *suite) testTags(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+	s.note(t, user, "n1", "go", "db")
+	s.note(t, user, "n2", "go")
+	s.note(t, user, "n3", "web")
+	s.note(t, other, "theirs", "go")
+
+	wantStrings(t, "any tag", s.titles(t, user, storage.NoteFilter{Tags: []string{"db", "web"}}), []string{"n1", "n3"})
+	wantStrings(t, "all tags", s.titles(t, user, storage.NoteFilter{Tags: []string{"go", "db"}, MatchAllTags: true}), []string{"n1"})
+
+	tags, err := s.repos.Tags.ListTags(ctx, user)
+	noErr(t, "ListTags", err)
+	want := []models.Tag{{Name: "db", NoteCount: 1}, {Name: "go", NoteCount: 2}, {Name: "web", NoteCount: 1}}
+	if !slices.Equal(tags, want) {
+		t.Fatalf("ListTags: got %v, want %v", tags, want)
+	}
+
+	err = s.repos.Tags.RenameTag(ctx, user, "db", "go")
+	wantErr(t, "RenameTag onto an existing tag", err, storage.ErrTagExists)
+	err = s.repos.Tags.RenameTag(ctx, user, "missing", "x")
+	wantErr(t, "RenameTag of a missing tag", err, storage.ErrNotFound)
+	noErr(t, "RenameTag", s.repos.Tags.RenameTag(ctx, user, "db", "sql"))
+	wantStrings(t, "renamed tag", s.titles(t, user, storage.NoteFilter{Tags: []string{"sql"}}), []string{"n1"})
+
+	noErr(t, "MergeTag", s.repos.Tags.MergeTag(ctx, user, "web", "go"))
+	wantStrings(t, "merged tag", s.titles(t, user, storage.NoteFilter{Tags: []string{"go"}}), []string{"n1", "n2", "n3"})
+
+	noErr(t, "DeleteTag", s.repos.Tags.DeleteTag(ctx, user, "go"))
+	err = s.repos.Tags.DeleteTag(ctx, user, "go")
+	wantErr(t, "DeleteTag of a deleted tag", err, storage.ErrNotFound)
+
+	tags, err = s.repos.Tags.ListTags(ctx, user)
+	noErr(t, "ListTags", err)
+	if want := []models.Tag{{Name: "sql", NoteCount: 1}}; !slices.Equal(tags, want) {
+		t.Fatalf("ListTags after delete: got %v, want %v", tags, want)
+	}
+
+	tags, err = s.repos.Tags.ListTags(ctx, other)
+	noErr(t, "ListTags", err)
+	if want := []models.Tag{{Name: "go", NoteCount: 1}}; !slices.Equal(tags, want) {
+		t.Fatalf("another user's tags changed: got %v", tags)
+	}
+}
+
+func (s *suite) testNotebooks(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+
+	work, err := s.repos.Notebooks.CreateNotebook(ctx, models.Notebook{UserID: user, Name: "work"})
+	noErr(t, "CreateNotebook", err)
+	projects, err := s.repos.Notebooks.CreateNotebook(ctx, models.Notebook{UserID: user, ParentID: &work, Name: "projects"})
+	noErr(t, "CreateNotebook", err)
+	theirs, err := s.repos.Notebooks.CreateNotebook(ctx, models.Notebook{UserID: other, Name: "theirs"})
+	noErr(t, "CreateNotebook", err)
+
+	_, err = s.repos.Notebooks.CreateNotebook(ctx, models.Notebook{UserID: user, ParentID: &theirs, Name: "x"})
+	wantErr(t, "CreateNotebook below another user's notebook", err, storage.ErrInvalidNotebook)
+	_, err = s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: "x", NotebookID: &theirs})
+	wantErr(t, "CreateNote in another user's notebook", err, storage.ErrInvalidNotebook)
+	_, err = s.repos.Notebooks.GetNotebook(ctx, user, theirs)
+	wantErr(t, "GetNotebook of another user's notebook", err, storage.ErrAccessDenied)
+	_, err = s.repos.Notebooks.GetNotebook(ctx, user, theirs+1_000_000)
+	wantErr(t, "GetNotebook of a missing notebook", err, storage.ErrNotFound)
+
+	top, err := s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: "top", NotebookID: &work})
+	noErr(t, "CreateNote", err)
+	deep := s.note(t, user, "deep")
+	noErr(t, "MoveNote", s.repos.Notes.MoveNote(ctx, user, deep, &projects))
+	s.note(t, user, "loose")
+
+	wantStrings(t, "notebook notes", s.titles(t, user, storage.NoteFilter{NotebookID: work}), []string{"top"})
+	wantStrings(t, "notebook notes with descendants",
+		s.titles(t, user, storage.NoteFilter{NotebookID: work, Descendants: true}), []string{"top", "deep"})
+
+	err = s.repos.Notebooks.MoveNotebook(ctx, user, work, &projects)
+	wantErr(t, "MoveNotebook below its child", err, storage.ErrNotebookCycle)
+	err = s.repos.Notebooks.MoveNotebook(ctx, user, work, &work)
+	wantErr(t, "MoveNotebook below itself", err, storage.ErrNotebookCycle)
+	noErr(t, "MoveNotebook", s.repos.Notebooks.MoveNotebook(ctx, user, projects, nil))
+	noErr(t, "RenameNotebook", s.repos.Notebooks.RenameNotebook(ctx, user, projects, "side projects"))
+
+	nb, err := s.repos.Notebooks.GetNotebook(ctx, user, projects)
+	noErr(t, "GetNotebook", err)
+	if nb.ParentID != nil || nb.Name != "side projects" {
+		t.Fatalf("GetNotebook after move and rename: %+v", nb)
+	}
+
+	notebooks, err := s.repos.Notebooks.GetNotebooks(ctx, user)
+	noErr(t, "GetNotebooks", err)
+	if len(notebooks) != 2 {
+		t.Fatalf("GetNotebooks returned %d notebooks, want 2", len(notebooks))
+	}
+
+	noErr(t, "DeleteNotebook", s.repos.Notebooks.DeleteNotebook(ctx, user, work))
+	n, err := s.repos.Notes.GetNote(ctx, user, top)
+	noErr(t, "GetNote", err)
+	if n.NotebookID != nil {
+		t.Fatalf("note still filed in a deleted notebook: %+v", n)
+	}
+	err = s.repos.Notebooks.DeleteNotebook(ctx, other, projects)
+	wantErr(t, "DeleteNotebook of another user's notebook", err, storage.ErrAccessDenied)
+}
+
+func (s *suite) testTrash(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+	keep := s.note(t, user, "keep", "shared")
+	gone := s.note(t, user, "gone", "shared")
+	purged := s.note(t, user, "purged")
+
+	noErr(t, "DeleteNote", s.repos.Notes.DeleteNote(ctx, user, gone))
+	err := s.repos.Notes.DeleteNote(ctx, user, gone)
+	wantErr(t, "DeleteNote of a trashed note", err, storage.ErrNotFound)
+	err = s.repos.Notes.UpdateNote(ctx, user, gone, models.UpdateNoteInput{Title: ptr("x")})
+	wantErr(t, "UpdateNote of a trashed note", err, storage.ErrNotFound)
+	err = s.repos.Notes.RestoreNote(ctx, user, keep)
+	wantErr(t, "RestoreNote of a live note", err, storage.ErrNotFound)
+	err = s.repos.Notes.PurgeNote(ctx, user, keep)
+	wantErr(t, "PurgeNote of a live note", err, storage.ErrNotFound)
+
+	wantStrings(t, "notes without the trashed one", s.titles(t, user, storage.NoteFilter{}), []string{"keep", "purged"})
+	tags, err := s.repos.Tags.ListTags(ctx, user)
+	noErr(t, "ListTags", err)
+	if want := []models.Tag{{Name: "shared", NoteCount: 1}}; !slices.Equal(tags, want) {
+		t.Fatalf("ListTags counted a trashed note: %v", tags)
+	}
+
+	trash, err := s.repos.Notes.GetTrash(ctx, user, 10, 0)
+	noErr(t, "GetTrash", err)
+	if len(trash) != 1 || trash[0].ID != gone || trash[0].DeletedAt == nil {
+		t.Fatalf("GetTrash returned %+v", trash)
+	}
+
+	err = s.repos.Notes.RestoreNote(ctx, other, gone)
+	wantErr(t, "RestoreNote of another user's note", err, storage.ErrAccessDenied)
+	noErr(t, "RestoreNote", s.repos.Notes.RestoreNote(ctx, user, gone))
+	_, err = s.repos.Notes.GetNote(ctx, user, gone)
+	noErr(t, "GetNote after restore", err)
+
+	noErr(t, "DeleteNote", s.repos.Notes.DeleteNote(ctx, user, gone))
+	noErr(t, "PurgeNote", s.repos.Notes.PurgeNote(ctx, user, gone))
+	err = s.repos.Notes.RestoreNote(ctx, user, gone)
+	wantErr(t, "RestoreNote of a purged note", err, storage.ErrNotFound)
+
+	noErr(t, "DeleteNote", s.repos.Notes.DeleteNote(ctx, user, purged))
+	n, err := s.repos.Notes.PurgeTrash(ctx, time.Now().Add(-time.Hour), 100)
+	noErr(t, "PurgeTrash", err)
+	if trash, _ := s.repos.Notes.GetTrash(ctx, user, 10, 0); len(trash) != 1 {
+		t.Fatalf("PurgeTrash removed a note within the retention, %d purged", n)
+	}
+	for {
+		// other runs may have left trash behind, purge in small batches
+		n, err = s.repos.Notes.PurgeTrash(ctx, time.Now().Add(time.Hour), 1)
+		noErr(t, "PurgeTrash", err)
+		if n == 0 {
+			break
+		}
+	}
+	trash, err = s.repos.Notes.GetTrash(ctx, user, 10, 0)
+	noErr(t, "GetTrash", err)
+	if len(trash) != 0 {
+		t.Fatalf("PurgeTrash left %d notes", len(trash))
+	}
+	wantStrings(t, "notes after purging", s.titles(t, user, storage.NoteFilter{}), []string{"keep"})
+}
+
+func (s *suite) testRevisions(t *testing.T) {
+	ctx := context.Background()
+	user := s.user(t)
+	id := s.note(t, user, "v0")
+
+	update := func(in models.UpdateNoteInput) {
+		t.Helper()
+		noErr(t, "UpdateNote", s.repos.Notes.UpdateNote(ctx, user, id, in))
+	}
+	revisionTitles := func() []string {
+		t.Helper()
+		revisions, err := s.repos.Revisions.GetRevisions(ctx, user, id, 100, 0)
+		noErr(t, "GetRevisions", err)
+		titles := []string{}
+		for _, r := range revisions {
+			titles = append(titles, r.Title)
+		}
+		return titles
+	}
+
+	wantStrings(t, "revisions of a new note", revisionTitles(), []string{})
+
+	update(models.UpdateNoteInput{Title: ptr("v1")})
+	// neither a tag change nor an update to the same values is a revision
+	update(models.UpdateNoteInput{Tags: &[]string{"t"}})
+	update(models.UpdateNoteInput{Title: ptr("v1")})
+	wantStrings(t, "revisions", revisionTitles(), []string{"v0"})
+
+	revisions, err := s.repos.Revisions.GetRevisions(ctx, user, id, 1, 0)
+	noErr(t, "GetRevisions", err)
+	first := revisions[0]
+	if first.NoteID != id || first.AuthorID != user || first.Content != "v0 content" || first.CreatedAt.IsZero() {
+		t.Fatalf("GetRevisions returned %+v", first)
+	}
+
+	rev, err := s.repos.Revisions.GetRevision(ctx, user, id, first.ID)
+	noErr(t, "GetRevision", err)
+	if rev != first {
+		t.Fatalf("GetRevision returned %+v, want %+v", rev, first)
+	}
+	_, err = s.repos.Revisions.GetRevision(ctx, user, id, first.ID+1_000_000)
+	wantErr(t, "GetRevision of a missing revision", err, storage.ErrNotFound)
+
+	for _, title := range []string{"v2", "v3", "v4"} {
+		update(models.UpdateNoteInput{Title: ptr(title)})
+	}
+	wantStrings(t, "revisions beyond the limit", revisionTitles(), []string{"v3", "v2", "v1"})
+	_, err = s.repos.Revisions.GetRevision(ctx, user, id, first.ID)
+	wantErr(t, "GetRevision of a dropped revision", err, storage.ErrNotFound)
+
+	noErr(t, "DeleteNote", s.repos.Notes.DeleteNote(ctx, user, id))
+	_, err = s.repos.Revisions.GetRevisions(ctx, user, id, 10, 0)
+	wantErr(t, "GetRevisions of a trashed note", err, storage.ErrNotFound)
+}
+
+func (s *suite) testRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+
+	first, err := s.repos.RefreshTokens.CreateRefreshToken(ctx, user)
+	noErr(t, "CreateRefreshToken", err)
+
+	owner, second, err := s.repos.RefreshTokens.RotateRefreshToken(ctx, first)
+	noErr(t, "RotateRefreshToken", err)
+	if owner != user || second == "" || second == first {
+		t.Fatalf("RotateRefreshToken returned user %d and token %q", owner, second)
+	}
+
+	// presenting a rotated token again revokes its whole family
+	_, _, err = s.repos.RefreshTokens.RotateRefreshToken(ctx, first)
+	wantErr(t, "RotateRefreshToken of a rotated token", err, storage.ErrRefreshTokenReused)
+	_, _, err = s.repos.RefreshTokens.RotateRefreshToken(ctx, second)
+	wantErr(t, "RotateRefreshToken after reuse", err, storage.ErrInvalidRefreshToken)
+	_, _, err = s.repos.RefreshTokens.RotateRefreshToken(ctx, "unknown")
+	wantErr(t, "RotateRefreshToken of an unknown token", err, storage.ErrInvalidRefreshToken)
+
+	token, err := s.repos.RefreshTokens.CreateRefreshToken(ctx, user)
+	noErr(t, "CreateRefreshToken", err)
+	err = s.repos.RefreshTokens.RevokeRefreshToken(ctx, other, token)
+	wantErr(t, "RevokeRefreshToken of another user's token", err, storage.ErrInvalidRefreshToken)
+	noErr(t, "RevokeRefreshToken", s.repos.RefreshTokens.RevokeRefreshToken(ctx, user, token))
+	_, _, err = s.repos.RefreshTokens.RotateRefreshToken(ctx, token)
+	wantErr(t, "RotateRefreshToken of a revoked token", err, storage.ErrInvalidRefreshToken)
+
+	mine, err := s.repos.RefreshTokens.CreateRefreshToken(ctx, user)
+	noErr(t, "CreateRefreshToken", err)
+	theirs, err := s.repos.RefreshTokens.CreateRefreshToken(ctx, other)
+	noErr(t, "CreateRefreshToken", err)
+	noErr(t, "RevokeUserRefreshTokens", s.repos.RefreshTokens.RevokeUserRefreshTokens(ctx, user))
+	_, _, err = s.repos.RefreshTokens.RotateRefreshToken(ctx, mine)
+	wantErr(t, "RotateRefreshToken after revoking the user's tokens", err, storage.ErrInvalidRefreshToken)
+	_, _, err = s.repos.RefreshTokens.RotateRefreshToken(ctx, theirs)
+	noErr(t, "RotateRefreshToken of another user's token", err)
+}
+
+func (s *suite) testMFA(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+
+	noErr(t, "SetPendingTOTPSecret", s.repos.MFA.SetPendingTOTPSecret(ctx, user, "SECRET"))
+	totp, err := s.repos.MFA.GetTOTP(ctx, user)
+	noErr(t, "GetTOTP", err)
+	if totp.Secret != "SECRET" || totp.Enabled {
+		t.Fatalf("GetTOTP of a pending secret returned %+v", totp)
+	}
+
+	noErr(t, "EnableTOTP", s.repos.MFA.EnableTOTP(ctx, user, 100, []string{"code-a", "code-b"}))
+	totp, err = s.repos.MFA.GetTOTP(ctx, user)
+	noErr(t, "GetTOTP", err)
+	if !totp.Enabled || totp.LastStep != 100 {
+		t.Fatalf("GetTOTP after enabling returned %+v", totp)
+	}
+	err = s.repos.MFA.SetPendingTOTPSecret(ctx, user, "OTHER")
+	wantErr(t, "SetPendingTOTPSecret with 2FA enabled", err, storage.ErrMFAAlreadyEnabled)
+
+	// a step is good for one login only, and so is any step before it
+	for _, step := range []struct {
+		step int64
+		ok   bool
+	}{{100, false}, {101, true}, {101, false}, {99, false}, {103, true}} {
+		ok, err := s.repos.MFA.UseTOTPStep(ctx, user, step.step)
+		noErr(t, "UseTOTPStep", err)
+		if ok != step.ok {
+			t.Fatalf("UseTOTPStep(%d) = %v, want %v", step.step, ok, step.ok)
+		}
+	}
+
+	for _, code := range []struct {
+		user int
+		code string
+		ok   bool
+	}{{user, "code-a", true}, {user, "code-a", false}, {user, "unknown", false}, {other, "code-b", false}} {
+		ok, err := s.repos.MFA.UseRecoveryCode(ctx, code.user, code.code)
+		noErr(t, "UseRecoveryCode", err)
+		if ok != code.ok {
+			t.Fatalf("UseRecoveryCode(%d, %q) = %v, want %v", code.user, code.code, ok, code.ok)
+		}
+	}
+
+	noErr(t, "DisableTOTP", s.repos.MFA.DisableTOTP(ctx, user))
+	totp, err = s.repos.MFA.GetTOTP(ctx, user)
+	noErr(t, "GetTOTP", err)
+	if totp.Enabled || totp.Secret != "" {
+		t.Fatalf("GetTOTP after disabling returned %+v", totp)
+	}
+	ok, err := s.repos.MFA.UseRecoveryCode(ctx, user, "code-b")
+	noErr(t, "UseRecoveryCode", err)
+	if ok {
+		t.Fatal("recovery code still works after disabling 2FA")
+	}
+}
+
+func (s *suite) testPasswordResets(t *testing.T) {
+	ctx := context.Background()
+	user := s.user(t)
+
+	token, err := s.repos.PasswordResets.CreateResetToken(ctx, user)
+	noErr(t, "CreateResetToken", err)
+	owner, err := s.repos.PasswordResets.ConsumeResetToken(ctx, token)
+	noErr(t, "ConsumeResetToken", err)
+	if owner != user {
+		t.Fatalf("ConsumeResetToken returned user %d, want %d", owner, user)
+	}
+	_, err = s.repos.PasswordResets.ConsumeResetToken(ctx, token)
+	wantErr(t, "ConsumeResetToken of a used token", err, storage.ErrInvalidResetToken)
+	_, err = s.repos.PasswordResets.ConsumeResetToken(ctx, "unknown")
+	wantErr(t, "ConsumeResetToken of an unknown token", err, storage.ErrInvalidResetToken)
+
+	// only the latest token requested is valid
+	older, err := s.repos.PasswordResets.CreateResetToken(ctx, user)
+	noErr(t, "CreateResetToken", err)
+	latest, err := s.repos.PasswordResets.CreateResetToken(ctx, user)
+	noErr(t, "CreateResetToken", err)
+	_, err = s.repos.PasswordResets.ConsumeResetToken(ctx, older)
+	wantErr(t, "ConsumeResetToken of a superseded token", err, storage.ErrInvalidResetToken)
+	_, err = s.repos.PasswordResets.ConsumeResetToken(ctx, latest)
+	noErr(t, "ConsumeResetToken", err)
+}
+
+func (s *suite) testRevocations(t *testing.T) {
+	ctx := context.Background()
+	user, other := s.user(t), s.user(t)
+	jti := fmt.Sprintf("jti-%d-%d", s.run, user)
+
+	noErr(t, "RevokeToken", s.repos.Revocations.RevokeToken(ctx, jti, user, time.Now().Add(time.Hour)))
+	revoked, err := s.repos.Revocations.IsTokenRevoked(ctx, jti)
+	noErr(t, "IsTokenRevoked", err)
+	if !revoked {
+		t.Fatal("revoked token is not reported as revoked")
+	}
+	revoked, err = s.repos.Revocations.IsTokenRevoked(ctx, jti+"-other")
+	noErr(t, "IsTokenRevoked", err)
+	if revoked {
+		t.Fatal("unknown token is reported as revoked")
+	}
+
+	before, err := s.repos.Revocations.TokensRevokedBefore(ctx, user)
+	noErr(t, "TokensRevokedBefore", err)
+	if !before.IsZero() {
+		t.Fatalf("new user has the watermark %v", before)
+	}
+
+	// the watermark has the one second resolution of iat and never lies ahead
+	revokedAt, err := s.repos.Revocations.RevokeAllTokens(ctx, user)
+	noErr(t, "RevokeAllTokens", err)
+	if revokedAt.IsZero() || revokedAt.After(time.Now()) || !revokedAt.Equal(revokedAt.Truncate(time.Second)) {
+		t.Fatalf("RevokeAllTokens returned the watermark %v", revokedAt)
+	}
+	before, err = s.repos.Revocations.TokensRevokedBefore(ctx, user)
+	noErr(t, "TokensRevokedBefore", err)
+	if !before.Equal(revokedAt) {
+		t.Fatalf("TokensRevokedBefore returned %v, want %v", before, revokedAt)
+	}
+
+	before, err = s.repos.Revocations.TokensRevokedBefore(ctx, other)
+	noErr(t, "TokensRevokedBefore", err)
+	if !before.IsZero() {
+		t.Fatalf("revoking one user's tokens set another's watermark to %v", before)
+	}
+}
+
+// testConcurrent updates notes from many goroutines, it is meant to be run
+// with the race detector.
+func (s *suite) testConcurrent(t *testing.T) {
+	ctx := context.Background()
+	user := s.user(t)
+	id := s.note(t, user, "shared")
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*3)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			title := fmt.Sprintf("title %d", i)
+			errs <- s.repos.Notes.UpdateNote(ctx, user, id, models.UpdateNoteInput{Title: &title, Tags: &[]string{"t"}})
+			_, err := s.repos.Notes.CreateNote(ctx, models.Note{UserID: user, Title: title})
+			errs <- err
+			_, err = s.repos.Notes.GetAllNotes(ctx, user, storage.NoteFilter{Limit: 100, Sort: "asc"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		noErr(t, "concurrent call", err)
+	}
+	if got := len(s.titles(t, user, storage.NoteFilter{})); got != workers+1 {
+		t.Fatalf("got %d notes after concurrent creates, want %d", got, workers+1)
+	}
+	revisions, err := s.repos.Revisions.GetRevisions(ctx, user, id, 100, 0)
+	noErr(t, "GetRevisions", err)
+	if len(revisions) != MaxRevisions {
+		t.Fatalf("got %d revisions after concurrent updates, want %d", len(revisions), MaxRevisions)
+	}
+}
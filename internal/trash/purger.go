@@ -0,0 +1,87 @@
+package trash
+
+import (
+	"context"
+	"github/yusupovkuzs/GoNotesApp/internal/config"
+	"github/yusupovkuzs/GoNotesApp/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+// batchSize bounds the notes removed by a single statement, so a large
+// backlog does not hold locks for long.
+const batchSize = 1000
+
+type Store interface {
+	PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int, error)
+}
+
+// Purger permanently removes notes that have been in the trash for longer
+// than the configured retention.
+type Purger struct {
+	store    Store
+	cfg      config.TrashConfig
+	log      *slog.Logger
+	interval time.Duration
+}
+
+func NewPurger(store Store, cfg config.TrashConfig, log *slog.Logger) *Purger {
+	interval := cfg.PurgeInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Purger{
+		store:    store,
+		cfg:      cfg,
+		log:      log.With(slog.String("op", "trash.Purger")),
+		interval: interval,
+	}
+}
+
+// Run purges once right away and then every purge interval until ctx is
+// cancelled. It returns at once if the retention is zero.
+func (p *Purger) Run(ctx context.Context) {
+	if p.cfg.Retention <= 0 {
+		p.log.Info("trash retention is zero, deleted notes are kept forever")
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes every note deleted before the retention window, in batches.
+func (p *Purger) Purge(ctx context.Context) {
+	deletedBefore := time.Now().Add(-p.cfg.Retention)
+
+	total := 0
+	for {
+		n, err := p.store.PurgeTrash(ctx, deletedBefore, batchSize)
+		total += n
+		if err != nil {
+			// a cancelled purge is picked up again by the next run
+			if ctx.Err() == nil {
+				p.log.Error("failed to purge trash", sl.Err(err))
+			}
+			break
+		}
+		if n < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		p.log.Info("purged trash", slog.Int("notes", total), slog.Time("deleted_before", deletedBefore))
+	}
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_language regconfig NOT NULL DEFAULT 'english';
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_language, title), 'A') ||
+    setweight(to_tsvector(search_language, content), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS notes_search_vector_idx ON notes USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS notes_search_vector_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_language;
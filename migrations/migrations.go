@@ -0,0 +1,10 @@
+// Package migrations embeds the schema migrations, so the binary doesn't
+// depend on the directory it is started from.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations at its root and the SQLite ones in sqlite/.
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,15 @@
+-- +goose Up
+-- a revision keeps a note's title and content as they were before an update
+CREATE TABLE IF NOT EXISTS note_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS note_revisions_note_id_idx ON note_revisions (note_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS note_revisions;
@@ -13,7 +13,7 @@ const (
 
 func SetupLogger(env string) *slog.Logger {
 	var log *slog.Logger
-	
+
 	switch env {
 	case envLocal:
 		log = slog.New(
@@ -28,6 +28,6 @@ func SetupLogger(env string) *slog.Logger {
 			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
 		)
 	}
-	
+
 	return log
 }
@@ -0,0 +1,39 @@
+package password
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// legacySalt is the static salt of the original hashing scheme. It was
+// prepended to the SHA-1 digest rather than hashed with the password.
+const legacySalt = "fjewohf7a434gfuoebf9w4"
+
+var legacyFormat = regexp.MustCompile("^[0-9a-f]+$")
+
+// legacySHA1 only verifies hashes created before per-user salts were introduced,
+// it never produces new ones.
+type legacySHA1 struct{}
+
+func (legacySHA1) Identify(encoded string) bool {
+	return len(encoded) == 2*(len(legacySalt)+sha1.Size) && legacyFormat.MatchString(encoded)
+}
+
+func (legacySHA1) Hash(string) (string, error) {
+	return "", errors.New("legacy sha1 hashes must not be created")
+}
+
+func (legacySHA1) Verify(password, encoded string) (bool, error) {
+	hash := sha1.New()
+	hash.Write([]byte(password))
+	other := fmt.Sprintf("%x", hash.Sum([]byte(legacySalt)))
+
+	return subtle.ConstantTimeCompare([]byte(encoded), []byte(other)) == 1, nil
+}
+
+func (legacySHA1) NeedsRehash(string) bool {
+	return true
+}
@@ -0,0 +1,97 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Scheme is a single password hashing algorithm.
+type Scheme interface {
+	// Identify reports whether the encoded hash was produced by this scheme.
+	Identify(encoded string) bool
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether the encoded hash was produced with weaker parameters.
+	NeedsRehash(encoded string) bool
+}
+
+type Config struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// Hasher hashes new passwords with the preferred scheme and verifies hashes
+// produced by any of the known ones, including legacy SHA-1 hashes.
+type Hasher struct {
+	preferred Scheme
+	schemes   []Scheme
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+func NewHasher(preferred Scheme, others ...Scheme) *Hasher {
+	return &Hasher{
+		preferred: preferred,
+		schemes:   append([]Scheme{preferred}, others...),
+	}
+}
+
+func New(cfg Config) (*Hasher, error) {
+	argon := NewArgon2id(Argon2idParams{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+	bcrypt := NewBcrypt(cfg.BcryptCost)
+
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		return NewHasher(argon, bcrypt, legacySHA1{}), nil
+	case AlgorithmBcrypt:
+		return NewHasher(bcrypt, argon, legacySHA1{}), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hashing algorithm %q", cfg.Algorithm)
+	}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *Hasher) Verify(password, encoded string) (bool, error) {
+	for _, s := range h.schemes {
+		if s.Identify(encoded) {
+			return s.Verify(password, encoded)
+		}
+	}
+
+	return false, ErrUnknownHashFormat
+}
+
+// NeedsRehash reports whether the hash should be replaced by one from the preferred scheme.
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	return !h.preferred.Identify(encoded) || h.preferred.NeedsRehash(encoded)
+}
+
+// VerifyDummy spends as much time as a real verification, so that callers can
+// hide whether an account exists.
+func (h *Hasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummy, _ = h.preferred.Hash("dummy password")
+	})
+	_, _ = h.preferred.Verify(password, h.dummy)
+}
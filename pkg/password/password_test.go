@@ -0,0 +1,160 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const secret = "correct horse 1"
+
+// cheap keeps argon2id fast enough for tests.
+var cheap = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestHasher(t *testing.T, algorithm string) *Hasher {
+	t.Helper()
+
+	h, err := New(Config{
+		Algorithm:         algorithm,
+		BcryptCost:        4,
+		Argon2Memory:      cheap.Memory,
+		Argon2Iterations:  cheap.Iterations,
+		Argon2Parallelism: cheap.Parallelism,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+func verify(t *testing.T, h *Hasher, password, encoded string) bool {
+	t.Helper()
+
+	ok, err := h.Verify(password, encoded)
+	if err != nil {
+		t.Fatalf("Verify(%q): %v", encoded, err)
+	}
+	return ok
+}
+
+func TestLegacySHA1(t *testing.T) {
+	digest := sha1.Sum([]byte(secret))
+	encoded := hex.EncodeToString(append([]byte(legacySalt), digest[:]...))
+
+	h := newTestHasher(t, AlgorithmArgon2id)
+	if !verify(t, h, secret, encoded) {
+		t.Fatal("legacy hash did not verify")
+	}
+	if verify(t, h, "wrong password", encoded) {
+		t.Fatal("legacy hash verified a wrong password")
+	}
+	if !h.NeedsRehash(encoded) {
+		t.Fatal("legacy hash does not need a rehash")
+	}
+	if _, err := (legacySHA1{}).Hash(secret); err == nil {
+		t.Fatal("legacy scheme created a hash")
+	}
+}
+
+func TestArgon2id(t *testing.T) {
+	a := NewArgon2id(cheap)
+	encoded, err := a.Hash(secret)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") || !a.Identify(encoded) {
+		t.Fatalf("unexpected encoding %q", encoded)
+	}
+
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatalf("decodeArgon2id: %v", err)
+	}
+	if p != cheap || len(salt) != int(cheap.SaltLength) || len(key) != int(cheap.KeyLength) {
+		t.Fatalf("decoded %+v, want %+v", p, cheap)
+	}
+
+	h := NewHasher(a)
+	if !verify(t, h, secret, encoded) || verify(t, h, "wrong password", encoded) {
+		t.Fatal("argon2id hash verified the wrong passwords")
+	}
+	if h.NeedsRehash(encoded) {
+		t.Fatal("hash with the current parameters needs a rehash")
+	}
+
+	stronger := cheap
+	stronger.Iterations++
+	if !NewArgon2id(stronger).NeedsRehash(encoded) {
+		t.Fatal("hash with fewer iterations does not need a rehash")
+	}
+}
+
+func TestBcrypt(t *testing.T) {
+	b := NewBcrypt(4)
+	encoded, err := b.Hash(secret)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$2a$04$") || !b.Identify(encoded) {
+		t.Fatalf("unexpected encoding %q", encoded)
+	}
+
+	h := NewHasher(b)
+	if !verify(t, h, secret, encoded) || verify(t, h, "wrong password", encoded) {
+		t.Fatal("bcrypt hash verified the wrong passwords")
+	}
+	if h.NeedsRehash(encoded) {
+		t.Fatal("hash with the current cost needs a rehash")
+	}
+	if !NewBcrypt(5).NeedsRehash(encoded) {
+		t.Fatal("hash with a lower cost does not need a rehash")
+	}
+}
+
+func TestSwitchingAlgorithm(t *testing.T) {
+	old := newTestHasher(t, AlgorithmBcrypt)
+	encoded, err := old.Hash(secret)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	h := newTestHasher(t, AlgorithmArgon2id)
+	if !verify(t, h, secret, encoded) {
+		t.Fatal("bcrypt hash did not verify after switching to argon2id")
+	}
+	if !h.NeedsRehash(encoded) {
+		t.Fatal("bcrypt hash does not need a rehash after switching to argon2id")
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	h := newTestHasher(t, AlgorithmArgon2id)
+
+	for _, encoded := range []string{
+		"",
+		"plain text",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"$argon2$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$version$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$not base64!",
+		"$2a$04$tooshort",
+		"$2a$xx$" + strings.Repeat("a", 53),
+	} {
+		ok, err := h.Verify(secret, encoded)
+		if ok || err == nil {
+			t.Errorf("Verify(%q) = %v, %v, want an error", encoded, ok, err)
+		}
+		if !h.NeedsRehash(encoded) {
+			t.Errorf("NeedsRehash(%q) = false", encoded)
+		}
+	}
+
+	if _, err := h.Verify(secret, "plain text"); !errors.Is(err, ErrUnknownHashFormat) {
+		t.Fatalf("Verify of an unknown format: got %v, want %v", err, ErrUnknownHashFormat)
+	}
+}
@@ -0,0 +1,137 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+const ContentTypeProblem = "application/problem+json"
+
+// StatusClientClosedRequest is the non-standard status nginx popularised for
+// requests the client gave up on before a response was written.
+const StatusClientClosedRequest = 499
+
+// Generic problem codes. Clients should branch on Code, never on Detail.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidBody      = "invalid_body"
+	CodeValidation       = "validation_failed"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeRequestCanceled  = "request_canceled"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object extended with a stable error code,
+// the request ID and, for validation failures, per-field messages.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+func NewProblem(r *http.Request, status int, code, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     statusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+func RespondProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	WriteProblem(w, NewProblem(r, status, code, detail))
+}
+
+// RespondInternalError hides the cause, it belongs in the log and not in the response.
+func RespondInternalError(w http.ResponseWriter, r *http.Request) {
+	RespondProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// RespondValidationError lists every failed field, joined into Detail and keyed by field in Errors.
+func RespondValidationError(w http.ResponseWriter, r *http.Request, errs validator.ValidationErrors) {
+	var errMsgs []string
+	fields := make(map[string]string, len(errs))
+
+	for _, err := range errs {
+		msg := fieldMessage(err)
+		if _, ok := fields[err.Field()]; !ok {
+			fields[err.Field()] = msg
+		}
+		errMsgs = append(errMsgs, fmt.Sprintf("field %s %s", err.Field(), msg))
+	}
+
+	p := NewProblem(r, http.StatusUnprocessableEntity, CodeValidation, strings.Join(errMsgs, ", "))
+	p.Errors = fields
+	WriteProblem(w, p)
+}
+
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func fieldMessage(err validator.FieldError) string {
+	switch err.ActualTag() {
+	case "required":
+		return "is a required field"
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", err.Param())
+	case "url":
+		return "is not a valid URL"
+	case "email":
+		return "is not a valid email address"
+	case "min":
+		if err.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at least %s items", err.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", err.Param())
+	case "max":
+		if err.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at most %s items", err.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", err.Param())
+	case "maxbytes":
+		return fmt.Sprintf("must be at most %s bytes long", err.Param())
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", err.Param())
+	case "numeric":
+		return "must contain digits only"
+	case "username":
+		return "may only contain letters, digits, '.', '_' and '-'"
+	case "password":
+		return "must contain at least one letter and one digit"
+	case "tag":
+		return "may only contain letters, digits, '_' and '-'"
+	default:
+		return "is not valid"
+	}
+}